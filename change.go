@@ -0,0 +1,139 @@
+package nextcfg
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nextpkg/nextcfg/reader"
+)
+
+// ChangeType is the kind of a Change
+type ChangeType int
+
+const (
+	// Added key path only exists in the new snapshot
+	Added ChangeType = iota + 1
+	// Removed key path only exists in the old snapshot
+	Removed
+	// Modified key path exists in both snapshots with different values
+	Modified
+)
+
+// String ...
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is the difference of a single key path between two snapshots
+type Change struct {
+	Path []string
+	Type ChangeType
+	// Old value, nil if added
+	Old interface{}
+	// New value, nil if removed
+	New interface{}
+	// Source which provided the new value, the old one if removed
+	Source string
+}
+
+// Key returns the dot separated key path
+func (c Change) Key() string {
+	return strings.Join(c.Path, ".")
+}
+
+// ChangeEvent is delivered to subscribers after each snapshot swap
+type ChangeEvent struct {
+	// Source which triggered the swap
+	Source string
+	// Version of the new snapshot
+	Version string
+	// Changes sorted by key path, only leaves are reported
+	Changes []Change
+	// Timestamp of the swap
+	Timestamp time.Time
+	// Err is set if the snapshot was rejected, the previous one stays live
+	Err error
+}
+
+// Has reports whether anything at or under the path has changed
+func (e ChangeEvent) Has(path ...string) bool {
+	for _, c := range e.Changes {
+		if hasPrefix(c.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// origin returns the source which provided the key or its closest parent, e.g. a list replaced as a whole
+func origin(prov reader.Provenance, path []string) string {
+	for i := len(path); i > 0; i-- {
+		if o, ok := prov[strings.Join(path[:i], ".")]; ok {
+			return o.Source
+		}
+	}
+	return ""
+}
+
+// diff compares two merged trees and appends the leaf changes
+func diff(path []string, old, new map[string]interface{}, changes []Change) []Change {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := append(path[:len(path):len(path)], k)
+
+		ov, inOld := old[k]
+		nv, inNew := new[k]
+
+		om, oldIsMap := ov.(map[string]interface{})
+		nm, newIsMap := nv.(map[string]interface{})
+
+		switch {
+		case !inOld && newIsMap && len(nm) > 0:
+			changes = diff(p, nil, nm, changes)
+		case !inOld:
+			changes = append(changes, Change{Path: p, Type: Added, New: nv})
+		case !inNew && oldIsMap && len(om) > 0:
+			changes = diff(p, om, nil, changes)
+		case !inNew:
+			changes = append(changes, Change{Path: p, Type: Removed, Old: ov})
+		case oldIsMap && newIsMap:
+			changes = diff(p, om, nm, changes)
+		case !reflect.DeepEqual(ov, nv):
+			changes = append(changes, Change{Path: p, Type: Modified, Old: ov, New: nv})
+		}
+	}
+
+	return changes
+}
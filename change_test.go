@@ -0,0 +1,47 @@
+package nextcfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	at := require.New(t)
+
+	old := map[string]interface{}{
+		"server": map[string]interface{}{
+			"host": "localhost",
+			"port": 80,
+		},
+		"debug": true,
+		"tags":  []interface{}{"a"},
+	}
+	new := map[string]interface{}{
+		"server": map[string]interface{}{
+			"host": "localhost",
+			"port": 8080,
+		},
+		"tags": []interface{}{"a", "b"},
+		"db": map[string]interface{}{
+			"host": "mysql",
+		},
+	}
+
+	changes := diff(nil, old, new, nil)
+	at.Equal([]Change{
+		{Path: []string{"db", "host"}, Type: Added, New: "mysql"},
+		{Path: []string{"debug"}, Type: Removed, Old: true},
+		{Path: []string{"server", "port"}, Type: Modified, Old: 80, New: 8080},
+		{Path: []string{"tags"}, Type: Modified, Old: []interface{}{"a"}, New: []interface{}{"a", "b"}},
+	}, changes)
+
+	ev := ChangeEvent{Changes: changes}
+	at.True(ev.Has("server"))
+	at.True(ev.Has("db", "host"))
+	at.False(ev.Has("server", "host"))
+	at.Equal("server.port", changes[2].Key())
+	at.Equal("modified", changes[2].Type.String())
+
+	at.Empty(diff(nil, old, old, nil))
+}
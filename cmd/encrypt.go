@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nextpkg/nextcfg/secrets"
+	"github.com/nextpkg/nextcfg/secrets/box"
+	"github.com/nextpkg/nextcfg/secrets/secretbox"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/curve25519"
+)
+
+var (
+	encryptScheme    string
+	encryptKey       string
+	encryptRecipient string
+	encryptKeyID     string
+	rotateOldKeys    []string
+)
+
+// encryptCmd prints the inline encrypted values to be pasted into the config files
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [value]...",
+	Short: "encrypt values as ENC[scheme,base64], the values are read from stdin if not given",
+	RunE: func(cli *cobra.Command, args []string) error {
+		s, opts, err := newSecrets()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			scanner := bufio.NewScanner(cli.InOrStdin())
+			for scanner.Scan() {
+				args = append(args, scanner.Text())
+			}
+			if err = scanner.Err(); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range args {
+			b, err := s.Encrypt([]byte(v), opts...)
+			if err != nil {
+				return err
+			}
+			cli.Println(secrets.Inline(encryptScheme, b))
+		}
+		return nil
+	},
+}
+
+// newSecrets creates the secrets of the scheme with the key files
+func newSecrets() (secrets.Secrets, []secrets.EncryptOption, error) {
+	key, err := readKey(encryptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch encryptScheme {
+	case "secretbox":
+		s := secretbox.NewSecrets()
+		if encryptKeyID == "" {
+			return s, nil, s.Init(secrets.Key(key))
+		}
+		kr := secrets.NewKeyring()
+		if err = kr.Add(encryptKeyID, key); err != nil {
+			return nil, nil, err
+		}
+		return s, nil, s.Init(secrets.Keys(kr))
+	case "box":
+		recipient, err := readKey(encryptRecipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the recipient decrypts with its private key and the public key of the sender
+		pub, err := curve25519.X25519(key, curve25519.Basepoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := box.NewSecrets()
+		opts := []secrets.EncryptOption{secrets.RecipientPublicKey(recipient)}
+		if encryptKeyID == "" {
+			return s, opts, s.Init(secrets.PrivateKey(key), secrets.PublicKey(pub))
+		}
+		kr := secrets.NewKeyring()
+		if err = kr.Add("sender", key); err != nil {
+			return nil, nil, err
+		}
+		return s, append(opts, secrets.RecipientKeyID(encryptKeyID)), s.Init(secrets.Keys(kr))
+	}
+	return nil, nil, fmt.Errorf("unknown scheme %q", encryptScheme)
+}
+
+// rotateCmd re-encrypts the inline values of the config files with the new key
+var rotateCmd = &cobra.Command{
+	Use:   "rotate file...",
+	Short: "re-encrypt the secretbox values of the config files with the key of -k and --id",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cli *cobra.Command, args []string) error {
+		if encryptKeyID == "" {
+			return fmt.Errorf("the id of the new key required")
+		}
+
+		key, err := readKey(encryptKey)
+		if err != nil {
+			return err
+		}
+		kr := secrets.NewKeyring()
+		if err = kr.Add(encryptKeyID, key); err != nil {
+			return err
+		}
+
+		// the old keys are given as id=path, or as path for the values without key id
+		var opts []secrets.Option
+		for _, old := range rotateOldKeys {
+			id, path, ok := strings.Cut(old, "=")
+			if !ok {
+				id, path = "", old
+			}
+			k, err := readKey(path)
+			if err != nil {
+				return err
+			}
+			if id == "" {
+				opts = append(opts, secrets.Key(k))
+			} else if err = kr.Add(id, k); err != nil {
+				return err
+			}
+		}
+
+		s := secretbox.NewSecrets()
+		if err = s.Init(append(opts, secrets.Keys(kr))...); err != nil {
+			return err
+		}
+
+		for _, f := range args {
+			n, err := secrets.ReencryptFile(f, "secretbox", s)
+			if err != nil {
+				return err
+			}
+			cli.Printf("%s: %d values re-encrypted\n", f, n)
+		}
+		return nil
+	},
+}
+
+// readKey reads a base64 encoded key file
+func readKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("key file required")
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+}
+
+func init() {
+	encryptCmd.Flags().StringVarP(&encryptScheme, "scheme", "s", "secretbox", "secretbox or box")
+	encryptCmd.Flags().StringVarP(&encryptKey, "key", "k", "", "base64 key file, the private key of the sender for box")
+	encryptCmd.Flags().StringVarP(&encryptRecipient, "recipient", "r", "", "base64 public key file of the recipient for box")
+	encryptCmd.PersistentFlags().StringVar(&encryptKeyID, "id", "", "key id embedded in the values, the key id of the recipient for box")
+	rotateCmd.Flags().StringVarP(&encryptKey, "key", "k", "", "base64 file of the new key")
+	rotateCmd.Flags().StringArrayVar(&rotateOldKeys, "old", nil, "old key as id=file, or file for the values without key id")
+
+	encryptCmd.AddCommand(rotateCmd)
+	rootCmd.AddCommand(encryptCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nextpkg/nextcfg/secrets"
+	"github.com/nextpkg/nextcfg/secrets/secretbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncrypt(t *testing.T) {
+	at := assert.New(t)
+
+	key := make([]byte, 32)
+	path := filepath.Join(t.TempDir(), "secret.key")
+	at.Nil(os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0o600))
+
+	var out bytes.Buffer
+	Root().SetOut(&out)
+	defer Root().SetOut(nil)
+	Root().SetIn(strings.NewReader("s3cret\n"))
+	defer Root().SetIn(nil)
+
+	Root().SetArgs([]string{"encrypt", "-k", path})
+	defer Root().SetArgs(nil)
+	_, err := Root().ExecuteC()
+	at.Nil(err)
+
+	scheme, ciphertext, err := secrets.ParseInline(strings.TrimSpace(out.String()))
+	at.Nil(err)
+	at.Equal("secretbox", scheme)
+
+	sb := secretbox.NewSecrets()
+	at.Nil(sb.Init(secrets.Key(key)))
+	plaintext, err := sb.Decrypt(ciphertext)
+	at.Nil(err)
+	at.Equal("s3cret", string(plaintext))
+}
+
+func TestEncryptRotate(t *testing.T) {
+	at := assert.New(t)
+
+	dir := t.TempDir()
+	oldKey, newKey := make([]byte, 32), make([]byte, 32)
+	newKey[0] = 1
+	oldPath, newPath := filepath.Join(dir, "old.key"), filepath.Join(dir, "new.key")
+	at.Nil(os.WriteFile(oldPath, []byte(base64.StdEncoding.EncodeToString(oldKey)), 0o600))
+	at.Nil(os.WriteFile(newPath, []byte(base64.StdEncoding.EncodeToString(newKey)), 0o600))
+
+	var out bytes.Buffer
+	Root().SetOut(&out)
+	defer Root().SetOut(nil)
+	defer Root().SetArgs(nil)
+	defer func() {
+		encryptKeyID, rotateOldKeys = "", nil
+	}()
+
+	Root().SetArgs([]string{"encrypt", "-k", oldPath, "--id", "old", "s3cret"})
+	_, err := Root().ExecuteC()
+	at.Nil(err)
+
+	conf := filepath.Join(dir, "config.json")
+	at.Nil(os.WriteFile(conf, []byte(`{"password": "`+strings.TrimSpace(out.String())+`"}`), 0o600))
+
+	Root().SetArgs([]string{"encrypt", "rotate", "-k", newPath, "--id", "new", "--old", "old=" + oldPath, conf})
+	_, err = Root().ExecuteC()
+	at.Nil(err)
+
+	b, err := os.ReadFile(conf)
+	at.Nil(err)
+	_, ciphertext, err := secrets.ParseInline(strings.TrimSuffix(strings.TrimPrefix(string(b), `{"password": "`), `"}`))
+	at.Nil(err)
+
+	id, _, ok := secrets.OpenEnvelope(ciphertext)
+	at.True(ok)
+	at.Equal("new", id)
+
+	kr := secrets.NewKeyring()
+	at.Nil(kr.Add("new", newKey))
+	sb := secretbox.NewSecrets()
+	at.Nil(sb.Init(secrets.Keys(kr)))
+	plaintext, err := sb.Decrypt(ciphertext)
+	at.Nil(err)
+	at.Equal("s3cret", string(plaintext))
+}
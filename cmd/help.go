@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"log/slog"
 )
 
@@ -29,8 +30,11 @@ func AddSubFlags(matchKey, matchValue string, flagSetFunc func() *FlagSet) {
 			return nil
 		}
 
-		cli.Flags().AddFlagSet(flagSetFunc().FlagSet)
-		return cli.Flags().Parse(args)
+		flags := cli.Flags()
+		flags.AddFlagSet(flagSetFunc().FlagSet)
+		// the root doesn't parse the flags itself, so its whitelist is applied here
+		flags.ParseErrorsWhitelist = pflag.ParseErrorsWhitelist(cli.FParseErrWhitelist)
+		return flags.Parse(args)
 	}
 	AppendCommand(&(rootCmd.RunE), current)
 }
@@ -27,6 +27,8 @@ var rootCmd = &cobra.Command{
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
 	// 忽略错误
 	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	// 子命令之外的参数留给应用
+	Args: cobra.ArbitraryArgs,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nextpkg/nextcfg/source/signed"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signKey    string
+	signOutput string
+	keygenOut  string
+)
+
+// signCmd writes the detached signatures of config files, verified by the signed source
+var signCmd = &cobra.Command{
+	Use:   "sign [file]...",
+	Short: "sign config files with an Ed25519 private key",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cli *cobra.Command, args []string) error {
+		if signOutput != "" && len(args) > 1 {
+			return fmt.Errorf("--output requires a single file")
+		}
+
+		b, err := os.ReadFile(signKey)
+		if err != nil {
+			return err
+		}
+		key, err := signed.ParsePrivateKey(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", signKey, err)
+		}
+
+		for _, file := range args {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			out := signOutput
+			if out == "" {
+				out = file + ".sig"
+			}
+			if err = os.WriteFile(out, signed.Sign(key, data), 0o644); err != nil {
+				return err
+			}
+			cli.Println("signed", file, "->", out)
+		}
+		return nil
+	},
+}
+
+// keygenCmd creates a key pair, the private key stays with the release pipeline
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "generate an Ed25519 key pair for signing",
+	Args:  cobra.NoArgs,
+	RunE: func(cli *cobra.Command, args []string) error {
+		pub, priv, err := signed.GenerateKey()
+		if err != nil {
+			return err
+		}
+
+		if err = os.WriteFile(keygenOut, signed.EncodeKey(priv.Seed()), 0o600); err != nil {
+			return err
+		}
+		if err = os.WriteFile(keygenOut+".pub", signed.EncodeKey(pub), 0o644); err != nil {
+			return err
+		}
+		cli.Println("private key:", keygenOut, "public key:", keygenOut+".pub")
+		return nil
+	},
+}
+
+func init() {
+	signCmd.Flags().StringVarP(&signKey, "key", "k", "signing.key", "private key file")
+	signCmd.Flags().StringVarP(&signOutput, "output", "o", "", "signature file, <file>.sig by default")
+	keygenCmd.Flags().StringVarP(&keygenOut, "output", "o", "signing.key", "private key file, the public key is written to <file>.pub")
+
+	signCmd.AddCommand(keygenCmd)
+	rootCmd.AddCommand(signCmd)
+}
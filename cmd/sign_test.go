@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextpkg/nextcfg/source/signed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	at := assert.New(t)
+
+	dir := t.TempDir()
+	key := filepath.Join(dir, "signing.key")
+	file := filepath.Join(dir, "config.json")
+	at.Nil(os.WriteFile(file, []byte(`{"foo": "bar"}`), 0o600))
+
+	Root().SetArgs([]string{"sign", "keygen", "-o", key})
+	defer Root().SetArgs(nil)
+	_, err := Root().ExecuteC()
+	at.Nil(err)
+
+	Root().SetArgs([]string{"sign", "-k", key, file})
+	_, err = Root().ExecuteC()
+	at.Nil(err)
+
+	b, err := os.ReadFile(key + ".pub")
+	at.Nil(err)
+	pub, err := signed.ParsePublicKey(b)
+	at.Nil(err)
+
+	sig, err := os.ReadFile(file + ".sig")
+	at.Nil(err)
+
+	b, err = os.ReadFile(key)
+	at.Nil(err)
+	priv, err := signed.ParsePrivateKey(b)
+	at.Nil(err)
+	at.Equal(pub, priv.Public())
+	at.Equal(string(signed.Sign(priv, []byte(`{"foo": "bar"}`))), string(sig))
+}
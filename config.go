@@ -6,6 +6,7 @@ import (
 
 	"github.com/nextpkg/nextcfg/loader"
 	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/schema"
 	"github.com/nextpkg/nextcfg/source"
 )
 
@@ -21,10 +22,36 @@ type Config interface {
 	Close() error
 	// Load config sources
 	Load(source ...source.Source) error
+	// Unload the sources named name
+	Unload(name string) error
+	// Replace the sources named name with another source, e.g. switch from file to consul
+	Replace(name string, s source.Source) error
 	// Sync Force a source change set sync
 	Sync() error
 	// Watch a value for changes
 	Watch(path ...string) (Watcher, error)
+	// Subscribe to the key path changes of every reload, call the returned func to unsubscribe
+	Subscribe(fn func(ChangeEvent)) func()
+	// Register a consumer to the reload transactions, call the returned func to unregister
+	Register(c Consumer) func()
+	// Explain where the effective values at or under the path come from
+	Explain(path ...string) reader.Provenance
+	// Redact the values at or under the path in Bytes, the change events and the audit trail, "*" matches any key
+	Redact(path ...string)
+	// Sources returns the raw change sets of the live snapshot per source, in load order
+	Sources() []*source.ChangeSet
+	// History of the latest merged snapshots, oldest first
+	History() []*loader.Snapshot
+	// Rollback the live config to an earlier snapshot of the history
+	Rollback(version string) error
+	// Override sets a runtime value above all the sources, it survives the reloads until it expires or is removed
+	Override(o loader.Override) error
+	// RemoveOverride removes the override of the path, who is recorded in the audit trail
+	RemoveOverride(who string, path ...string) error
+	// Overrides in effect, oldest first
+	Overrides() []loader.Override
+	// Audit trail of the overrides, oldest first
+	Audit() []loader.AuditEntry
 	// SetState 设置服务状态
 	SetState(state bool)
 	// GetState 获取服务状态
@@ -37,12 +64,32 @@ type Watcher interface {
 	Stop() error
 }
 
+// Consumer takes part in the reload transactions of a Config.
+// A new snapshot is only applied if every consumer prepared it successfully,
+// otherwise all of them abort and stay on the previous snapshot.
+// The methods are called inside the transaction and must not call back into the Config,
+// e.g. Set, Override or Load would deadlock; do that from a Subscribe callback instead.
+type Consumer interface {
+	// Prepare builds and validates a candidate from the new values without applying it
+	Prepare(reader.Value) error
+	// Commit applies the prepared candidate
+	Commit()
+	// Abort drops the prepared candidate
+	Abort()
+}
+
 // Options ...
 type Options struct {
 	Loader loader.Loader
 	Reader reader.Reader
 	Source []source.Source
 
+	// SnapshotFile keeps the last known good snapshot, which is restored if loading fails at boot
+	SnapshotFile string
+
+	// Schema validates every merged snapshot before it goes live
+	Schema *schema.Schema
+
 	// for alternative data
 	Context context.Context
 }
@@ -92,3 +139,23 @@ func Load(source ...source.Source) error {
 func Watch(path ...string) (Watcher, error) {
 	return DefaultConfig.Watch(path...)
 }
+
+// Subscribe to the changes of every reload
+func Subscribe(fn func(ChangeEvent)) func() {
+	return DefaultConfig.Subscribe(fn)
+}
+
+// Explain where the effective values at or under the path come from
+func Explain(path ...string) reader.Provenance {
+	return DefaultConfig.Explain(path...)
+}
+
+// Redact the values at or under the path in the outputs of the config
+func Redact(path ...string) {
+	DefaultConfig.Redact(path...)
+}
+
+// Sources returns the raw change sets of the loaded sources
+func Sources() []*source.ChangeSet {
+	return DefaultConfig.Sources()
+}
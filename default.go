@@ -1,11 +1,14 @@
 package nextcfg
 
 import (
-	"bytes"
 	"log/slog"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/bytedance/sonic"
+	"github.com/mohae/deepcopy"
 	"github.com/nextpkg/nextcfg/loader"
 	"github.com/nextpkg/nextcfg/loader/memory"
 	"github.com/nextpkg/nextcfg/reader"
@@ -19,11 +22,32 @@ type config struct {
 	opts  Options
 	state bool
 
+	// serializes snapshot swaps
+	swap sync.Mutex
+	// serializes the changes of the loader which are reverted if their snapshot is rejected
+	mutate sync.Mutex
+
+	// the callbacks of the swaps, run in order once the swap lock is released
+	queueMu  sync.Mutex
+	queue    []func()
+	draining bool
+
 	sync.RWMutex
 	// the current snapshot
 	snap *loader.Snapshot
 	// the current values
 	val reader.Values
+	// change subscribers
+	subs  map[uint64]func(ChangeEvent)
+	subID uint64
+	// reload transaction consumers
+	consumers map[uint64]Consumer
+	// whether sources have been loaded without error
+	loaded bool
+	// the sources of the last known good snapshot booted from, which are not back yet
+	missing []string
+	// the secret key paths
+	redacted [][]string
 }
 
 type watcher struct {
@@ -31,6 +55,8 @@ type watcher struct {
 	rd    reader.Reader
 	path  []string
 	value reader.Value
+	// version of the snapshot the value is read from
+	version string
 }
 
 func newConfig(opts ...Option) (Config, error) {
@@ -51,6 +77,8 @@ func (c *config) Init(opts ...Option) error {
 		Reader: json.NewReader(),
 	}
 	c.exit = make(chan bool)
+	c.subs = make(map[uint64]func(ChangeEvent))
+	c.consumers = make(map[uint64]Consumer)
 	for _, o := range opts {
 		o(&c.opts)
 	}
@@ -62,6 +90,9 @@ func (c *config) Init(opts ...Option) error {
 
 	err := c.opts.Loader.Load(c.opts.Source...)
 	if err != nil {
+		if c.restore(err) {
+			return nil
+		}
 		return err
 	}
 
@@ -70,14 +101,129 @@ func (c *config) Init(opts ...Option) error {
 		return err
 	}
 
-	c.val, err = c.opts.Reader.Values(c.snap.ChangeSet)
+	c.val, err = c.values(c.snap)
 	if err != nil {
 		return err
 	}
 
+	if err = c.validate(c.val); err != nil {
+		return err
+	}
+
+	c.loaded = true
+	c.persist(c.snap)
+
 	return nil
 }
 
+// restore boots from the last known good snapshot if loading failed before anything was loaded.
+// It takes the version of the current loader snapshot, so only later changes replace it.
+func (c *config) restore(cause error) bool {
+	c.RLock()
+	loaded := c.loaded
+	c.RUnlock()
+
+	if c.opts.SnapshotFile == "" || loaded {
+		return false
+	}
+
+	lkg, err := loader.Open(c.opts.SnapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("open snapshot failed.", slog.String("err", err.Error()))
+		}
+		return false
+	}
+
+	val, err := c.values(lkg)
+	if err != nil {
+		slog.Warn("restore snapshot failed.", slog.String("err", err.Error()))
+		return false
+	}
+
+	snap, err := c.opts.Loader.Snapshot()
+	if err != nil {
+		return false
+	}
+
+	// the secrets were saved redacted, the hash of the restored content tells them from the ones of the sources
+	c.Lock()
+	c.snap = &loader.Snapshot{
+		ChangeSet:  lkg.ChangeSet,
+		Version:    snap.Version,
+		Seq:        snap.Seq,
+		Hash:       loader.Hash(val.Bytes()),
+		Provenance: lkg.Provenance,
+		Sources:    lkg.Sources,
+	}
+	c.val = val
+	c.missing = c.missing[:0]
+	for _, cs := range lkg.Sources {
+		c.missing = append(c.missing, cs.Source)
+	}
+	c.Unlock()
+
+	slog.Warn("loading failed, restored the last known good snapshot.",
+		slog.String("file", c.opts.SnapshotFile),
+		slog.String("version", lkg.Version),
+		slog.String("err", cause.Error()))
+
+	return true
+}
+
+// persist saves snap as the last known good snapshot.
+// After a boot from the last known good snapshot, it's kept until every source of it is back,
+// so a failed source doesn't go missing from it at the next boot.
+func (c *config) persist(snap *loader.Snapshot) {
+	if c.opts.SnapshotFile == "" {
+		return
+	}
+
+	c.Lock()
+	missing := c.missing[:0]
+	for _, name := range c.missing {
+		if !hasSource(snap, name) {
+			missing = append(missing, name)
+		}
+	}
+	c.missing = missing
+	c.Unlock()
+
+	if len(missing) > 0 {
+		slog.Warn("snapshot not saved, sources are missing.", slog.Any("sources", missing))
+		return
+	}
+
+	// the inline ENC[...] values are kept, the other secrets are restored redacted
+	if err := loader.Save(c.opts.SnapshotFile, c.redactSnapshot(snap)); err != nil {
+		slog.Error("save snapshot failed.", slog.String("err", err.Error()))
+	}
+}
+
+// hasSource reports whether a source named name has been merged into snap
+func hasSource(snap *loader.Snapshot, name string) bool {
+	for _, cs := range snap.Sources {
+		if cs.Source == name {
+			return true
+		}
+	}
+	return false
+}
+
+// forget stops waiting for the sources named name to come back, e.g. they are unloaded
+func (c *config) forget(name string) {
+	c.Lock()
+	defer c.Unlock()
+
+	missing := c.missing[:0]
+	for _, n := range c.missing {
+		if n != name {
+			missing = append(missing, n)
+		}
+	}
+	c.missing = missing
+}
+
 // Options 配置选项
 func (c *config) Options() Options {
 	return c.opts
@@ -92,20 +238,11 @@ func (c *config) run() {
 				return err
 			}
 
-			c.Lock()
-
-			if c.snap.Version >= snap.Version {
-				c.Unlock()
-				continue
+			err = c.apply(snap)
+			c.dispatch()
+			if err != nil {
+				slog.Error("apply snapshot failed.", slog.String("err", err.Error()))
 			}
-
-			// save
-			c.snap = snap
-
-			// set values
-			c.val, _ = c.opts.Reader.Values(snap.ChangeSet)
-
-			c.Unlock()
 		}
 	}
 
@@ -148,11 +285,21 @@ func (c *config) run() {
 	}
 }
 
-// Map To map
+// Map To map, the values of the secret paths are redacted
 func (c *config) Map() map[string]interface{} {
 	c.RLock()
-	defer c.RUnlock()
-	return c.val.Map()
+	val := c.val
+	c.RUnlock()
+
+	m := val.Map()
+	secrets := c.secrets(val)
+	if len(secrets) == 0 {
+		return m
+	}
+
+	m, _ = deepcopy.Copy(m).(map[string]interface{})
+	redact(m, nil, secrets, false)
+	return m
 }
 
 // Scan Scan anything
@@ -164,6 +311,8 @@ func (c *config) Scan(v interface{}) error {
 
 // Sync loads all the sources, calls the parser and updates the config
 func (c *config) Sync() error {
+	defer c.dispatch()
+
 	if err := c.opts.Loader.Sync(); err != nil {
 		return errors.Wrap(err, "sync() failed")
 	}
@@ -173,21 +322,252 @@ func (c *config) Sync() error {
 		return err
 	}
 
-	c.Lock()
-	defer c.Unlock()
+	return c.apply(snap)
+}
 
-	c.snap = snap
+// apply swaps in a newer snapshot and queues the notifications of what changed, the caller dispatches them.
+// The snapshot is prepared by every consumer first and rejected if any of them fails.
+func (c *config) apply(snap *loader.Snapshot) error {
+	c.swap.Lock()
+	defer c.swap.Unlock()
+
+	c.RLock()
+	old := c.val
+	cur := c.snap
+	consumers := make([]Consumer, 0, len(c.consumers))
+	for _, cs := range c.consumers {
+		consumers = append(consumers, cs)
+	}
+	c.RUnlock()
+
+	if cur != nil && !snap.Newer(cur) {
+		return nil
+	}
+
+	// same content under a newer version, the consumers are left alone
+	if cur != nil && cur.Hash != "" && cur.Hash == snap.Hash {
+		c.Lock()
+		c.snap = snap
+		c.Unlock()
+		return nil
+	}
 
-	var val reader.Values
-	val, err = c.opts.Reader.Values(snap.ChangeSet)
+	val, err := c.values(snap)
 	if err != nil {
 		return err
 	}
+
+	// the schema is checked before any consumer prepares
+	if err = c.validate(val); err == nil {
+		// phase one, every consumer prepares the new values
+		for i, cs := range consumers {
+			if err = cs.Prepare(val.Get()); err != nil {
+				for _, prepared := range consumers[:i] {
+					prepared.Abort()
+				}
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		slog.Error("snapshot rejected.",
+			slog.String("version", snap.Version),
+			slog.String("source", snap.ChangeSet.Source),
+			slog.String("err", err.Error()))
+		c.notify(old, val, cur, snap, err)
+		return errors.Wrap(err, "snapshot rejected")
+	}
+
+	c.Lock()
+	c.snap = snap
 	c.val = val
+	c.Unlock()
+
+	// phase two, every consumer commits, their callbacks run after the swap
+	for _, cs := range consumers {
+		cs.Commit()
+		if n, ok := cs.(notifier); ok {
+			if fn := n.committed(); fn != nil {
+				c.enqueue(fn)
+			}
+		}
+	}
+
+	c.persist(snap)
 
+	c.notify(old, val, cur, snap, nil)
+
+	return nil
+}
+
+// values reads the merged change set of the snapshot, the values know where their keys come from
+func (c *config) values(snap *loader.Snapshot) (reader.Values, error) {
+	val, err := c.opts.Reader.Values(snap.ChangeSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := val.(reader.Traced); ok && snap.Provenance != nil {
+		t.Trace(snap.Provenance)
+	}
+	return val, nil
+}
+
+// validate checks the merged values against the schema
+func (c *config) validate(val reader.Values) error {
+	if c.opts.Schema == nil {
+		return nil
+	}
+
+	if err := c.opts.Schema.Validate(reader.Reveal(val)); err != nil {
+		return errors.Wrap(err, "schema validation failed")
+	}
 	return nil
 }
 
+// notify queues the changes between old and new for the subscribers, c.swap must be locked.
+// The changes are attributed by the provenance of the snapshots, prev is the one old is read from.
+func (c *config) notify(old, new reader.Values, prev, snap *loader.Snapshot, err error) {
+	c.RLock()
+	subs := make([]func(ChangeEvent), 0, len(c.subs))
+	for _, fn := range c.subs {
+		subs = append(subs, fn)
+	}
+	c.RUnlock()
+
+	if old == nil || len(subs) == 0 {
+		return
+	}
+
+	changes := diff(nil, old.Map(), new.Map(), nil)
+	if len(changes) == 0 {
+		return
+	}
+
+	secrets := c.secrets(old, new)
+	for i, ch := range changes {
+		if isSecret(ch.Path, secrets) {
+			changes[i].Old, changes[i].New = asSecret(ch.Old), asSecret(ch.New)
+		}
+
+		prov := snap.Provenance
+		if ch.Type == Removed && prev != nil {
+			prov = prev.Provenance
+		}
+		if changes[i].Source = origin(prov, ch.Path); changes[i].Source == "" {
+			changes[i].Source = snap.ChangeSet.Source
+		}
+	}
+
+	ev := ChangeEvent{
+		Source:    snap.ChangeSet.Source,
+		Version:   snap.Version,
+		Changes:   changes,
+		Timestamp: time.Now(),
+		Err:       err,
+	}
+
+	c.enqueue(func() {
+		for _, fn := range subs {
+			fn(ev)
+		}
+	})
+}
+
+// enqueue defers fn until the swap lock is released, c.swap must be locked
+func (c *config) enqueue(fn func()) {
+	c.queueMu.Lock()
+	c.queue = append(c.queue, fn)
+	c.queueMu.Unlock()
+}
+
+// dispatch runs the queued callbacks in order, it must be called without holding c.swap.
+// The callbacks may call back into the config, the callbacks queued meanwhile are run
+// by the dispatch in progress instead of a nested one.
+func (c *config) dispatch() {
+	for {
+		c.queueMu.Lock()
+		if c.draining || len(c.queue) == 0 {
+			c.queueMu.Unlock()
+			return
+		}
+		fn := c.queue[0]
+		c.queue = c.queue[1:]
+		c.draining = true
+		c.queueMu.Unlock()
+
+		func() {
+			defer func() {
+				c.queueMu.Lock()
+				c.draining = false
+				c.queueMu.Unlock()
+			}()
+			fn()
+		}()
+	}
+}
+
+// Explain returns where the effective values at or under the path come from
+func (c *config) Explain(path ...string) reader.Provenance {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.snap == nil || c.snap.Provenance == nil {
+		return reader.Provenance{}
+	}
+
+	return c.snap.Provenance.Filter(path...)
+}
+
+// Sources returns copies of the raw change sets the live snapshot was merged from, with the secrets redacted
+func (c *config) Sources() []*source.ChangeSet {
+	c.RLock()
+	snap := c.snap
+	c.RUnlock()
+
+	if snap == nil {
+		return nil
+	}
+
+	sets := loader.Copy(snap).Sources
+	c.redactSets(sets...)
+	return sets
+}
+
+// Subscribe calls fn with the changes of every snapshot swap until the returned func is called.
+// fn is called in order once the swap is done, so it may call Set, Override or Load; it should not block.
+func (c *config) Subscribe(fn func(ChangeEvent)) func() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.subID++
+	id := c.subID
+	c.subs[id] = fn
+
+	return func() {
+		c.Lock()
+		defer c.Unlock()
+		delete(c.subs, id)
+	}
+}
+
+// Register adds a consumer to the reload transactions until the returned func is called
+func (c *config) Register(cs Consumer) func() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.subID++
+	id := c.subID
+	c.consumers[id] = cs
+
+	return func() {
+		c.Lock()
+		defer c.Unlock()
+		delete(c.consumers, id)
+	}
+}
+
 // Close 关闭配置
 func (c *config) Close() error {
 	select {
@@ -196,7 +576,7 @@ func (c *config) Close() error {
 	default:
 		close(c.exit)
 	}
-	return nil
+	return c.opts.Loader.Close()
 }
 
 // Get 获取配置项对应的内容
@@ -213,28 +593,20 @@ func (c *config) Get(path ...string) reader.Value {
 	return newValue()
 }
 
-// Set 设置配置项及内容
+// Set 设置配置项及内容，作为运行时覆盖项生效，重载后依然保留
 func (c *config) Set(val interface{}, path ...string) {
-	c.Lock()
-	defer c.Unlock()
-
-	if c.val != nil {
-		c.val.Set(val, path...)
+	err := c.Override(loader.Override{Path: path, Value: val, Who: "config.Set"})
+	if err != nil {
+		slog.Error("set failed.", slog.String("path", strings.Join(path, ".")), slog.String("err", err.Error()))
 	}
-
-	return
 }
 
-// Del 删除配置项
+// Del 删除配置项，作为运行时覆盖项生效，重载后依然保留
 func (c *config) Del(path ...string) {
-	c.Lock()
-	defer c.Unlock()
-
-	if c.val != nil {
-		c.val.Del(path...)
+	err := c.Override(loader.Override{Path: path, Delete: true, Who: "config.Del"})
+	if err != nil {
+		slog.Error("del failed.", slog.String("path", strings.Join(path, ".")), slog.String("err", err.Error()))
 	}
-
-	return
 }
 
 // Bytes 以[]bytes格式返回配置内容
@@ -246,12 +618,61 @@ func (c *config) Bytes() []byte {
 		return []byte{}
 	}
 
-	return c.val.Bytes()
+	b := c.val.Bytes()
+	if len(c.redacted) == 0 {
+		return b
+	}
+
+	var tree interface{}
+	if err := sonic.Unmarshal(b, &tree); err != nil {
+		return b
+	}
+	b, _ = sonic.Marshal(redact(tree, nil, c.redacted, false))
+	return b
+}
+
+// Redact marks the values at or under the path as secret.
+// The snapshot file is saved again, it may have been saved before with the value in plaintext.
+func (c *config) Redact(path ...string) {
+	c.swap.Lock()
+	defer c.swap.Unlock()
+
+	c.Lock()
+	for _, p := range c.redacted {
+		if strings.Join(p, ".") == strings.Join(path, ".") {
+			c.Unlock()
+			return
+		}
+	}
+	c.redacted = append(c.redacted, path)
+	snap, loaded := c.snap, c.loaded
+	c.Unlock()
+
+	if loaded {
+		c.persist(snap)
+	}
+}
+
+// secrets returns the redacted paths with the decrypted ones of the values
+func (c *config) secrets(values ...reader.Values) [][]string {
+	c.RLock()
+	secrets := append([][]string(nil), c.redacted...)
+	c.RUnlock()
+
+	for _, v := range values {
+		if d, ok := v.(reader.Decrypted); ok {
+			secrets = append(secrets, d.Decrypted()...)
+		}
+	}
+	return secrets
 }
 
 // Load 加载配置
 func (c *config) Load(sources ...source.Source) error {
+	defer c.dispatch()
+
 	if err := c.opts.Loader.Load(sources...); err != nil {
+		c.restore(err)
 		return errors.Wrap(err, "load() failed")
 	}
 
@@ -260,19 +681,111 @@ func (c *config) Load(sources ...source.Source) error {
 		return err
 	}
 
+	if err = c.apply(snap); err != nil {
+		return err
+	}
+
 	c.Lock()
-	defer c.Unlock()
+	c.loaded = true
+	c.Unlock()
 
-	c.snap = snap
+	return nil
+}
 
-	var val reader.Values
-	val, err = c.opts.Reader.Values(snap.ChangeSet)
-	if err != nil {
+// Unload stops watching the sources named name and drops their values
+func (c *config) Unload(name string) error {
+	return c.change(func() error {
+		if err := c.opts.Loader.Unload(name); err != nil {
+			return errors.Wrap(err, "unload() failed")
+		}
+		c.forget(name)
+		return nil
+	})
+}
+
+// Replace swaps the sources named name for s without restarting
+func (c *config) Replace(name string, s source.Source) error {
+	return c.change(func() error {
+		if err := c.opts.Loader.Replace(name, s); err != nil {
+			return errors.Wrap(err, "replace() failed")
+		}
+		c.forget(name)
+		return nil
+	})
+}
+
+// History returns the latest snapshots of the loader with the secrets redacted, oldest first
+func (c *config) History() []*loader.Snapshot {
+	history := c.opts.Loader.History()
+	for i, snap := range history {
+		history[i] = c.redactSnapshot(snap)
+	}
+	return history
+}
+
+// Rollback reverts the live config to an earlier snapshot of the history
+func (c *config) Rollback(version string) error {
+	return c.change(func() error {
+		return errors.Wrap(c.opts.Loader.Rollback(version), "rollback() failed")
+	})
+}
+
+// Override sets a runtime value above all the sources, it survives the reloads until it expires or is removed
+func (c *config) Override(o loader.Override) error {
+	return c.change(func() error {
+		return errors.Wrap(c.opts.Loader.Override(o), "override() failed")
+	})
+}
+
+// RemoveOverride removes the override of the path, who is recorded in the audit trail
+func (c *config) RemoveOverride(who string, path ...string) error {
+	return c.change(func() error {
+		return errors.Wrap(c.opts.Loader.RemoveOverride(who, path...), "remove override failed")
+	})
+}
+
+// Overrides in effect, oldest first
+func (c *config) Overrides() []loader.Override {
+	return c.opts.Loader.Overrides()
+}
+
+// Audit trail of the overrides, oldest first
+func (c *config) Audit() []loader.AuditEntry {
+	audits := c.opts.Loader.Audit()
+
+	secrets := c.secrets()
+	for i, a := range audits {
+		if isSecret(a.Override.Path, secrets) {
+			audits[i].Override.Value = asSecret(a.Override.Value)
+		}
+	}
+	return audits
+}
+
+// change runs fn to change the loader and applies the snapshot without waiting for the watcher.
+// If the snapshot is rejected, e.g. by the schema or a consumer, the loader change is reverted.
+func (c *config) change(fn func() error) error {
+	defer c.dispatch()
+
+	c.mutate.Lock()
+	defer c.mutate.Unlock()
+
+	if err := fn(); err != nil {
 		return err
 	}
-	c.val = val
 
-	return nil
+	snap, err := c.opts.Loader.Snapshot()
+	if err == nil {
+		err = c.apply(snap)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if rErr := c.opts.Loader.Revert(); rErr != nil {
+		slog.Error("revert failed.", slog.String("err", rErr.Error()))
+	}
+	return err
 }
 
 // SetState 设置服务状态
@@ -292,11 +805,19 @@ func (c *config) Watch(path ...string) (Watcher, error) {
 		return nil, errors.Wrap(err, "watch() failed")
 	}
 
+	c.RLock()
+	var version string
+	if c.snap != nil {
+		version = c.snap.Version
+	}
+	c.RUnlock()
+
 	return &watcher{
-		lw:    w,
-		rd:    c.opts.Reader,
-		path:  path,
-		value: c.Get(path...),
+		lw:      w,
+		rd:      c.opts.Reader,
+		path:    path,
+		value:   c.Get(path...),
+		version: version,
 	}, nil
 }
 
@@ -313,10 +834,11 @@ func (w *watcher) Next() (reader.Value, error) {
 			return nil, err
 		}
 
-		// only process changes
-		if bytes.Equal(w.value.Bytes(), s.ChangeSet.Data) {
+		// only process changes, the version only moves when the content hash does
+		if s.Version == w.version {
 			continue
 		}
+		w.version = s.Version
 
 		v, err := w.rd.Values(s.ChangeSet)
 		if err != nil {
@@ -1,4 +1,4 @@
-package nextcfg
+package nextcfg_test
 
 import (
 	"fmt"
@@ -9,6 +9,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/schema"
 	"github.com/nextpkg/nextcfg/source"
 	"github.com/nextpkg/nextcfg/source/env"
 	"github.com/nextpkg/nextcfg/source/file"
@@ -58,7 +62,7 @@ func TestConfigLoadWithGoodFile(t *testing.T) {
 	}()
 
 	// Create new config
-	conf, err := NewConfig()
+	conf, err := nextcfg.NewConfig()
 	at.Nil(err)
 
 	// Load file source
@@ -80,7 +84,7 @@ func TestConfigLoadWithInvalidFile(t *testing.T) {
 	}()
 
 	// Create new config
-	conf, err := NewConfig()
+	conf, err := nextcfg.NewConfig()
 	if err != nil {
 		t.Fatalf("Expected no error but got %v", err)
 	}
@@ -116,7 +120,7 @@ func TestConfigMerge(t *testing.T) {
 	}()
 	at.Nil(os.Setenv("AMQP_HOST", "rabbit.testing.com"))
 
-	conf, err := NewConfig()
+	conf, err := nextcfg.NewConfig()
 	at.Nil(err)
 
 	err = conf.Load(
@@ -148,7 +152,7 @@ func TestConfigWatcherDirtyOverride(t *testing.T) {
 		ss[i] = memory.NewSource(memory.WithJSON([]byte(fmt.Sprintf(`{"key%d": "val%d"}`, i, i))))
 	}
 
-	conf, _ := NewConfig()
+	conf, _ := nextcfg.NewConfig()
 
 	for _, s := range ss {
 		_ = conf.Load(s)
@@ -161,3 +165,515 @@ func TestConfigWatcherDirtyOverride(t *testing.T) {
 		at.Equal(v, conf.Get(k).String(""))
 	}
 }
+
+func TestConfigSubscribe(t *testing.T) {
+
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"server": {"host": "localhost", "port": 80}}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"server": {"host": "localhost", "port": 8080}}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.Equal("memory", ev.Source)
+		at.Len(ev.Changes, 1)
+		at.Equal("server.port", ev.Changes[0].Key())
+		at.Equal("memory", ev.Changes[0].Source)
+		at.Equal(nextcfg.Modified, ev.Changes[0].Type)
+		at.True(ev.Has("server"))
+		at.False(ev.Has("server", "host"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+}
+
+func TestConfigSubscribeSources(t *testing.T) {
+
+	at := assert.New(t)
+
+	base := memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"host": "base", "port": 80}`)))
+	local := memory.NewSource(source.WithName("local"), memory.WithJSON([]byte(`{"host": "local", "debug": true}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(base), nextcfg.WithSource(local))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// the host falls back to base, the debug flag local provided is gone
+	at.Nil(conf.Unload("local"))
+
+	select {
+	case ev := <-events:
+		at.Len(ev.Changes, 2)
+		at.Equal("debug", ev.Changes[0].Key())
+		at.Equal(nextcfg.Removed, ev.Changes[0].Type)
+		at.Equal("local", ev.Changes[0].Source)
+		at.Equal("host", ev.Changes[1].Key())
+		at.Equal("base", ev.Changes[1].New)
+		at.Equal("base", ev.Changes[1].Source)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+	at.Nil(conf.Close())
+}
+
+func TestConfigSubscribeReenter(t *testing.T) {
+
+	at := assert.New(t)
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"port": 80}`)))))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 2)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		// the subscriber may change the config, e.g. to derive a key
+		if ev.Has("port") {
+			conf.Set(conf.Get("port").Int(0)+1, "admin", "port")
+		}
+		events <- ev
+	})
+	defer cancel()
+
+	done := make(chan bool)
+	go func() {
+		conf.Set(8080, "port")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("set deadlocked")
+	}
+
+	// the events of the nested set come after the outer ones
+	at.True((<-events).Has("port"))
+	at.True((<-events).Has("admin", "port"))
+	at.Equal(8081, conf.Get("admin", "port").Int(0))
+	at.Nil(conf.Close())
+}
+
+func TestConfigSnapshotFile(t *testing.T) {
+
+	at := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	conf, err := nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"foo": "bar"}`)))),
+	)
+	at.Nil(err)
+	at.Nil(conf.Close())
+
+	// the source is unavailable at boot
+	conf, err = nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(file.NewSource(file.WithPath("/i/do/not/exists.json"))),
+	)
+	at.Nil(err)
+	at.Equal("bar", conf.Get("foo").String(""))
+	at.Nil(conf.Close())
+
+	// without snapshot file it fails
+	_, err = nextcfg.NewConfig(
+		nextcfg.WithSource(file.NewSource(file.WithPath("/i/do/not/exists.json"))),
+	)
+	at.NotNil(err)
+}
+
+func TestConfigSnapshotFileMissingSource(t *testing.T) {
+
+	at := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.json")
+	local := filepath.Join(dir, "local.json")
+	at.Nil(os.WriteFile(local, []byte(`{"db": {"host": "10.0.0.1"}}`), 0o600))
+
+	base := memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"name": "v1"}`)))
+	conf, err := nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(base),
+		nextcfg.WithSource(file.NewSource(file.WithPath(local))),
+	)
+	at.Nil(err)
+	at.Nil(conf.Close())
+
+	// the file is unavailable at boot
+	at.Nil(os.Remove(local))
+	base = memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"name": "v1"}`)))
+	conf, err = nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(base),
+		nextcfg.WithSource(file.NewSource(file.WithPath(local))),
+	)
+	at.Nil(err)
+	at.Equal("10.0.0.1", conf.Get("db", "host").String(""))
+
+	// a change of another source doesn't replace the snapshot file with a partial config
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(base.Write(&source.ChangeSet{Data: []byte(`{"name": "v2"}`), Format: "json"}))
+	at.Eventually(func() bool { return conf.Get("name").String("") == "v2" }, 5*time.Second, 10*time.Millisecond)
+
+	lkg, err := loader.Open(path)
+	at.Nil(err)
+	at.Len(lkg.Sources, 2)
+
+	// the file is read again once it's back
+	at.Nil(os.WriteFile(local, []byte(`{"db": {"host": "10.0.0.2"}}`), 0o600))
+	at.Eventually(func() bool { return conf.Get("db", "host").String("") == "10.0.0.2" }, 5*time.Second, 10*time.Millisecond)
+	at.Equal("v2", conf.Get("name").String(""))
+
+	at.Eventually(func() bool {
+		lkg, err := loader.Open(path)
+		return err == nil && strings.Contains(string(lkg.ChangeSet.Data), "10.0.0.2")
+	}, 5*time.Second, 10*time.Millisecond)
+	at.Nil(conf.Close())
+}
+
+func TestConfigRollback(t *testing.T) {
+
+	at := assert.New(t)
+
+	conf, err := nextcfg.NewConfig()
+	at.Nil(err)
+
+	at.Nil(conf.Load(memory.NewSource(memory.WithJSON([]byte(`{"foo": "v1"}`)))))
+	history := conf.History()
+	at.NotEmpty(history)
+	v1 := history[len(history)-1].Version
+
+	at.Nil(conf.Load(memory.NewSource(memory.WithJSON([]byte(`{"foo": "v2"}`)))))
+	at.Equal("v2", conf.Get("foo").String(""))
+
+	at.Nil(conf.Rollback(v1))
+	at.Equal("v1", conf.Get("foo").String(""))
+	at.NotNil(conf.Rollback("unknown"))
+	at.Nil(conf.Close())
+}
+
+func TestConfigSchema(t *testing.T) {
+
+	at := assert.New(t)
+
+	s := schema.MustCompile([]byte(`{
+		"type": "object",
+		"properties": {"port": {"type": "integer", "maximum": 65535}},
+		"additionalProperties": false
+	}`))
+
+	_, err := nextcfg.NewConfig(
+		nextcfg.WithSchema(s),
+		nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"prot": 80}`)))),
+	)
+	at.NotNil(err)
+
+	conf, err := nextcfg.NewConfig(
+		nextcfg.WithSchema(s),
+		nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"port": 80}`)))),
+	)
+	at.Nil(err)
+
+	err = conf.Load(memory.NewSource(memory.WithJSON([]byte(`{"port": 70000}`))))
+	at.NotNil(err)
+	at.Contains(err.Error(), "/port: must be <= 65535")
+	at.Equal(80, conf.Get("port").Int(0))
+	at.Nil(conf.Close())
+}
+
+func TestConfigExplain(t *testing.T) {
+
+	at := assert.New(t)
+
+	at.Nil(os.Setenv("AMQP_HOST", "rabbit.testing.com"))
+	defer os.Unsetenv("AMQP_HOST")
+
+	conf, err := nextcfg.NewConfig(
+		nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"amqp": {"host": "rabbit.platform", "port": 80}}`)))),
+		nextcfg.WithSource(env.NewSource(env.WithPrefix("AMQP"))),
+	)
+	at.Nil(err)
+
+	at.Equal("env", conf.Explain("amqp", "host")["amqp.host"].Source)
+	at.Equal("memory", conf.Explain("amqp", "port")["amqp.port"].Source)
+	at.Len(conf.Explain("amqp"), 2)
+	at.Empty(conf.Explain("unknown"))
+	at.Nil(conf.Close())
+}
+
+func TestConfigOverride(t *testing.T) {
+
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"feature": {"a": false, "b": false}, "name": "foo"}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	at.Nil(conf.Override(loader.Override{Path: []string{"feature", "a"}, Value: true, Who: "alice", Why: "canary"}))
+	at.True(conf.Get("feature", "a").Bool(false))
+	at.Equal("override", conf.Explain("feature", "a")["feature.a"].Source)
+	<-events
+
+	conf.Del("name")
+	at.Equal("", conf.Get("name").String(""))
+	<-events
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"feature": {"a": false, "b": true}, "name": "bar"}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.True(ev.Has("feature", "b"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+
+	// the overrides survive the reload
+	at.True(conf.Get("feature", "a").Bool(false))
+	at.True(conf.Get("feature", "b").Bool(false))
+	at.Equal("", conf.Get("name").String(""))
+	at.Len(conf.Overrides(), 2)
+
+	at.Nil(conf.RemoveOverride("bob", "name"))
+	at.Equal("bar", conf.Get("name").String(""))
+	at.Len(conf.Audit(), 3)
+	at.Nil(conf.Close())
+}
+
+func TestConfigOverrideRejected(t *testing.T) {
+
+	at := assert.New(t)
+
+	s := schema.MustCompile([]byte(`{
+		"type": "object",
+		"properties": {"port": {"type": "integer", "maximum": 65535}},
+		"required": ["port"]
+	}`))
+
+	base := memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"port": 80}`)))
+	conf, err := nextcfg.NewConfig(nextcfg.WithSchema(s), nextcfg.WithSource(base))
+	at.Nil(err)
+
+	// the override is reverted along with its audit entry
+	err = conf.Override(loader.Override{Path: []string{"port"}, Value: 70000, Who: "alice"})
+	at.NotNil(err)
+	at.Contains(err.Error(), "/port: must be <= 65535")
+	at.Equal(80, conf.Get("port").Int(0))
+	at.Empty(conf.Overrides())
+	audit := conf.Audit()
+	at.Len(audit, 1)
+	at.True(audit[0].Rejected)
+
+	at.Nil(conf.Override(loader.Override{Path: []string{"port"}, Value: 81, Who: "alice"}))
+	at.NotNil(conf.Override(loader.Override{Path: []string{"port"}, Value: 70000, Who: "bob"}))
+	at.Equal(81, conf.Get("port").Int(0))
+	at.Len(conf.Overrides(), 1)
+	at.Equal("alice", conf.Overrides()[0].Who)
+	at.Nil(conf.RemoveOverride("alice", "port"))
+
+	// the source is back after its unload is rejected
+	at.NotNil(conf.Unload("base"))
+	at.Equal(80, conf.Get("port").Int(0))
+
+	// so is a rollback to the snapshot of a rejected override
+	for _, snap := range conf.History() {
+		if strings.Contains(string(snap.ChangeSet.Data), "70000") {
+			at.NotNil(conf.Rollback(snap.Version))
+		}
+	}
+	at.Equal(80, conf.Get("port").Int(0))
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(base.Write(&source.ChangeSet{Data: []byte(`{"port": 82}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.True(ev.Has("port"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+	at.Equal(82, conf.Get("port").Int(0))
+	at.Nil(conf.Close())
+}
+
+func TestConfigReplace(t *testing.T) {
+
+	at := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	at.Nil(os.WriteFile(path, []byte(`{"host": "file"}`), 0o600))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"host": "memory"}`)))))
+	at.Nil(err)
+
+	at.Nil(conf.Replace("memory", file.NewSource(file.WithPath(path))))
+	at.Equal("file", conf.Get("host").String(""))
+	at.Equal("file:"+path, conf.Explain("host")["host"].Source)
+
+	// the file sources are told apart by their paths
+	at.NotNil(conf.Unload("file"))
+	at.Nil(conf.Unload("file:" + path))
+	at.Equal("", conf.Get("host").String(""))
+	at.NotNil(conf.Unload("file:" + path))
+	at.Nil(conf.Close())
+}
+
+func TestConfigSources(t *testing.T) {
+
+	at := assert.New(t)
+
+	base := memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"host": "base", "port": 80}`)))
+	local := memory.NewSource(source.WithName("local"), memory.WithJSON([]byte(`{"host": "local"}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(base), nextcfg.WithSource(local))
+	at.Nil(err)
+
+	sources := conf.Sources()
+	at.Len(sources, 2)
+	at.Equal("base", sources[0].Source)
+	at.JSONEq(`{"host": "base", "port": 80}`, string(sources[0].Data))
+	at.Equal("local", sources[1].Source)
+	at.Equal("local", conf.Explain("host")["host"].Source)
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(base.Write(&source.ChangeSet{Data: []byte(`{"host": "base", "port": 8080}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.Equal("base", ev.Source)
+		at.Equal("base", conf.Explain("port")["port"].Source)
+		at.JSONEq(`{"host": "base", "port": 8080}`, string(conf.Sources()[0].Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+
+	at.Nil(conf.Unload("local"))
+	at.Len(conf.Sources(), 1)
+	at.Equal("base", conf.Get("host").String(""))
+	at.Nil(conf.Close())
+}
+
+func TestConfigWatchNoopReload(t *testing.T) {
+
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"host": "localhost", "port": 80}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+
+	w, err := conf.Watch()
+	at.Nil(err)
+
+	values := make(chan reader.Value, 2)
+	go func() {
+		for {
+			v, err := w.Next()
+			if err != nil {
+				return
+			}
+			values <- v
+		}
+	}()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+
+	// the same content in another order is not a change
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"port": 80, "host": "localhost"}`), Format: "json"}))
+
+	select {
+	case v := <-values:
+		t.Fatalf("unexpected change %s", v.Bytes())
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"port": 8080, "host": "localhost"}`), Format: "json"}))
+
+	select {
+	case v := <-values:
+		at.JSONEq(`{"host": "localhost", "port": 8080}`, string(v.Bytes()))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change timeout")
+	}
+
+	at.Nil(w.Stop())
+	at.Nil(conf.Close())
+}
+
+func TestConfigNoopReload(t *testing.T) {
+
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"host": "localhost", "port": 80}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 2)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+
+	// the same content in another order is not a change
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"port": 80, "host": "localhost"}`), Format: "json"}))
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"host": "localhost", "port": 8080}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.Equal([]string{"port"}, ev.Changes[0].Path)
+		at.True(strings.HasPrefix(ev.Version, "2-"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected change event %s", ev.Version)
+	case <-time.After(100 * time.Millisecond):
+	}
+	at.Nil(conf.Close())
+}
@@ -0,0 +1,9 @@
+package nextcfg
+
+// ResetDefault restores the process default loaders and config, a nil loaders clears the default
+func ResetDefault(l *Loaders, cfg Config) {
+	ldLock.Lock()
+	defer ldLock.Unlock()
+	ld = l
+	DefaultConfig = cfg
+}
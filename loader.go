@@ -6,6 +6,8 @@ import (
 	"sync"
 
 	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/schema"
+	"github.com/pkg/errors"
 	"go.uber.org/atomic"
 )
 
@@ -14,49 +16,66 @@ type Loader func(o *Loaders)
 
 // Loaders 配置加载器
 type Loaders struct {
-	ctx  context.Context
-	once sync.Once
-	data atomic.Value
-	scan Scanner
-	cfg  Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+	data   atomic.Value
+	scan   Scanner
+	cfg    Config
+	shared bool
+	// validates the snapshots against the schema generated from the bound struct
+	useSchema bool
+	schema    *schema.Schema
+	// fails the scans on unknown keys and type mismatches
+	strict bool
+
+	sync.RWMutex
+	// callbacks fired after each successful reload
+	watchers []func(old, new interface{})
 }
 
 // Scanner scans the config into struct
 type Scanner func(reader.Value, interface{}) error
 
 // InitLoader 初始化配置加载器
+// Every loaders owns its config and watcher, use AsDefault to make it the process default
 func InitLoader(lds ...Loader) *Loaders {
-	cfg, err := NewConfig()
+	l, err := newLoaders(lds...)
 	if err != nil {
 		panic(err)
 	}
+	return l
+}
 
+func newLoaders(lds ...Loader) (*Loaders, error) {
 	l := &Loaders{
 		ctx: context.Background(),
-		cfg: cfg,
 	}
 
 	for _, o := range lds {
 		o(l)
 	}
-	if nil == ld {
-		ldLock.Lock()
-		defer ldLock.Unlock()
-		if nil == ld {
-			ld = l
-			DefaultConfig = ld.cfg
-		}
+
+	l.GetCfg()
+	if l.err != nil {
+		return nil, l.err
 	}
-	return l
+
+	l.ctx, l.cancel = context.WithCancel(l.ctx)
+
+	return l, nil
 }
 
 // Init inits configurator
+// The first Init becomes the process default used by GetCopy and GetOnce,
+// libraries should use New or InitLoader which never touch the default.
 func Init(t interface{}, lds ...Loader) *Loaders {
 	if t == nil {
 		panic("you should not use nil template")
 	}
-	if ld == nil && len(lds) == 0 {
-		lds = append(lds, WithContext(context.Background()))
+	if Default() == nil {
+		lds = append(lds, AsDefault())
 	}
 
 	l := InitLoader(lds...)
@@ -68,13 +87,40 @@ func Init(t interface{}, lds ...Loader) *Loaders {
 
 // watch data addr
 func watchDataAddr(t interface{}, l *Loaders) {
+	if err := l.bind(t); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Default returns the process default loaders, nil if there is none
+func Default() *Loaders {
+	ldLock.Lock()
+	defer ldLock.Unlock()
+	return ld
+}
+
+// Close stops taking part in reloads and closes the config unless it is shared
+func (l *Loaders) Close() error {
+	l.cancel()
+	if l.shared {
+		return nil
+	}
+	return l.cfg.Close()
+}
+
+// bind stores the struct which the config is scanned into
+func (l *Loaders) bind(t interface{}) error {
+	if t == nil {
+		return errors.New("you should not use nil template")
+	}
+
 	data := t
 
 	variable := reflect.ValueOf(data)
 
 	if variable.Kind() != reflect.Ptr {
 		if variable.Kind() != reflect.Struct {
-			panic("non-struct")
+			return errors.New("non-struct")
 		}
 
 		pointer := reflect.New(variable.Type())
@@ -84,25 +130,38 @@ func watchDataAddr(t interface{}, l *Loaders) {
 
 	if variable.Kind() == reflect.Ptr {
 		if variable.Elem().Kind() != reflect.Struct {
-			panic("non-valid-struct")
+			return errors.New("non-valid-struct")
 		}
 	}
-	if nil == ld.data.Load() {
-		ldLock.Lock()
-		defer ldLock.Unlock()
-		if nil == ld.data.Load() {
-			ld.data.Store(data)
+
+	if l.useSchema {
+		s, err := schema.FromStruct(data)
+		if err != nil {
+			return err
 		}
+		l.schema = s
 	}
+
 	l.data.Store(data)
+	return nil
 }
 
 // Reload reload config
 func Reload(t interface{}, lds ...Loader) {
-	Init(t, lds...)
+	Init(t, append(lds, AsDefault())...)
 	GetCopy()
 }
 
+// AsDefault makes the loaders the process default, DefaultConfig is replaced by its config
+func AsDefault() Loader {
+	return func(l *Loaders) {
+		ldLock.Lock()
+		defer ldLock.Unlock()
+		ld = l
+		DefaultConfig = l.GetCfg()
+	}
+}
+
 // WithContext attach context
 func WithContext(ctx context.Context) Loader {
 	return func(l *Loaders) {
@@ -110,9 +169,35 @@ func WithContext(ctx context.Context) Loader {
 	}
 }
 
+// WithConfig shares cfg with other loaders, their reloads either all succeed or all fail.
+// It must be the first Loader, the others load their sources into the config.
+func WithConfig(cfg Config) Loader {
+	return func(l *Loaders) {
+		l.cfg = cfg
+		l.shared = true
+	}
+}
+
 // WithScanner sets custom scanner
 func WithScanner(scanner Scanner) Loader {
 	return func(l *Loaders) {
 		l.scan = scanner
 	}
 }
+
+// WithStructSchema validates the snapshots against a schema generated from the struct,
+// so a misspelled key is rejected instead of being silently ignored.
+// Don't use it with WithConfig if the other loaders read keys unknown to the struct.
+func WithStructSchema() Loader {
+	return func(l *Loaders) {
+		l.useSchema = true
+	}
+}
+
+// WithStrict reports every key unknown to the struct and every value which can not be converted,
+// the config is rejected at startup and at reload time
+func WithStrict() Loader {
+	return func(l *Loaders) {
+		l.strict = true
+	}
+}
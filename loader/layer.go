@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// Layer is the merge priority of a source, the sources of a higher layer override the lower ones.
+// The sources of the same layer are merged in the order they are loaded.
+type Layer int
+
+const (
+	// LayerDefaults built-in default values
+	LayerDefaults Layer = iota * 10
+	// LayerFile shared config files, the sources without a layer are in it
+	LayerFile
+	// LayerProfile config files of the active profile
+	LayerProfile
+	// LayerLocal machine local config files
+	LayerLocal
+	// LayerEnv environment variables
+	LayerEnv
+	// LayerFlag command line flags
+	LayerFlag
+	// LayerOverride runtime overrides
+	LayerOverride
+)
+
+// String ...
+func (l Layer) String() string {
+	switch l {
+	case LayerDefaults:
+		return "defaults"
+	case LayerFile:
+		return "file"
+	case LayerProfile:
+		return "profile"
+	case LayerLocal:
+		return "local"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	case LayerOverride:
+		return "override"
+	}
+	return fmt.Sprintf("layer(%d)", int(l))
+}
+
+// Layered is implemented by the sources which know their layer
+type Layered interface {
+	Layer() Layer
+}
+
+type layered struct {
+	source.Source
+	layer Layer
+}
+
+// Layer ...
+func (l *layered) Layer() Layer {
+	return l.layer
+}
+
+// InLayer puts a source in the layer
+func InLayer(layer Layer, s source.Source) source.Source {
+	return &layered{Source: s, layer: layer}
+}
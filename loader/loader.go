@@ -3,6 +3,8 @@ package loader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 
 	"github.com/nextpkg/nextcfg/reader"
 	"github.com/nextpkg/nextcfg/source"
@@ -14,12 +16,30 @@ type Loader interface {
 	Close() error
 	// Load the sources
 	Load(...source.Source) error
+	// Unload stops watching the sources named name and drops their change sets
+	Unload(name string) error
+	// Replace the sources named name with another source
+	Replace(name string, s source.Source) error
 	// A Snapshot of loaded config
 	Snapshot() (*Snapshot, error)
 	// Sync Force sync of sources
 	Sync() error
 	// Watch for changes
 	Watch(...string) (Watcher, error)
+	// History of the latest snapshots, oldest first
+	History() []*Snapshot
+	// Rollback to an earlier snapshot of the history
+	Rollback(version string) error
+	// Override sets a runtime value above all the sources
+	Override(o Override) error
+	// RemoveOverride removes the override of the path, who is recorded in the audit trail
+	RemoveOverride(who string, path ...string) error
+	// Overrides in effect, oldest first
+	Overrides() []Override
+	// Audit trail of the overrides, oldest first
+	Audit() []AuditEntry
+	// Revert undoes the last Override, RemoveOverride, Unload, Replace or Rollback, e.g. if its snapshot was rejected
+	Revert() error
 	// String Name of loader
 	String() string
 }
@@ -38,8 +58,16 @@ type Watcher interface {
 type Snapshot struct {
 	// The merged ChangeSet
 	ChangeSet *source.ChangeSet
-	// Deterministic and comparable version of the snapshot
+	// Version of the snapshot made of the sequence number and the content hash
 	Version string
+	// Seq is the sequence number, it only increases when the content changes
+	Seq uint64
+	// Hash of the merged content
+	Hash string
+	// Provenance of the merged keys, nil if the reader doesn't trace them
+	Provenance reader.Provenance
+	// Sources are the raw change sets of the loaded sources in load order
+	Sources []*source.ChangeSet
 }
 
 // Options loader选项
@@ -57,8 +85,30 @@ type Option func(o *Options)
 // Copy snapshot
 func Copy(s *Snapshot) *Snapshot {
 	snapshot := *(s.ChangeSet)
+
+	var sources []*source.ChangeSet
+	for _, cs := range s.Sources {
+		c := *cs
+		sources = append(sources, &c)
+	}
+
 	return &Snapshot{
-		ChangeSet: &snapshot,
-		Version:   s.Version,
+		ChangeSet:  &snapshot,
+		Version:    s.Version,
+		Seq:        s.Seq,
+		Hash:       s.Hash,
+		Provenance: s.Provenance,
+		Sources:    sources,
 	}
 }
+
+// Newer reports whether s is a later snapshot than o
+func (s *Snapshot) Newer(o *Snapshot) bool {
+	return o == nil || s.Seq > o.Seq
+}
+
+// Hash returns the content hash of the merged values, b should be canonical JSON
+func Hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -5,6 +5,7 @@ import (
 	"container/list"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +17,12 @@ import (
 	"github.com/pkg/errors"
 )
 
+// DefaultHistory is the number of snapshots kept for rollback
+var DefaultHistory = 10
+
+// RetryInterval is how often a source whose first read failed is read again
+var RetryInterval = time.Second
+
 type memory struct {
 	exit chan bool
 	opts loader.Options
@@ -25,16 +32,51 @@ type memory struct {
 	snap *loader.Snapshot
 	// the current values
 	val reader.Values
-	// all the changesets
-	sets []*source.ChangeSet
-	// all the sources
-	sources  []source.Source
+	// the loaded sources in load order
+	entries []*entry
+	// the layers of the source names
+	layers   map[string]loader.Layer
 	watchers *list.List
+	// the latest snapshots, oldest first
+	history []*loader.Snapshot
+	size    int
+	// sequence number of the current snapshot
+	seq uint64
+	// runtime overrides above all the sets, oldest first
+	overrides []loader.Override
+	audits    []loader.AuditEntry
+	// undoes the last change of the overrides or the sources
+	undo func() error
+}
+
+// entry is a loaded source with its latest change set
+type entry struct {
+	source source.Source
+	// nil until the first successful read
+	set *source.ChangeSet
+	// the sets are merged by layer
+	layer loader.Layer
+	// closed when the source is unloaded
+	stop chan bool
+}
+
+func (e *entry) stopped() bool {
+	select {
+	case <-e.stop:
+		return true
+	default:
+		return false
+	}
 }
 
 type updateValue struct {
-	version string
-	value   reader.Value
+	seq        uint64
+	version    string
+	hash       string
+	source     string
+	value      reader.Value
+	provenance reader.Provenance
+	sources    []*source.ChangeSet
 }
 
 type watcher struct {
@@ -42,51 +84,47 @@ type watcher struct {
 	path    []string
 	value   reader.Value
 	reader  reader.Reader
+	seq     uint64
 	version string
 	updates chan updateValue
 }
 
-func (m *memory) watch(idx int, s source.Source) {
+func (m *memory) watch(e *entry) {
+	s := e.source
+
 	// watches a source for changes
-	watch := func(idx int, s source.Watcher) error {
+	watch := func(sw source.Watcher) error {
 		for {
 			// get change set
-			cs, err := s.Next()
+			cs, err := sw.Next()
 			if err != nil {
 				return err
 			}
 
-			m.Lock()
-
-			// save
-			m.sets[idx] = cs
-
-			// merge sets
-			set, err := m.opts.Reader.Merge(m.sets...)
-			if err != nil {
-				m.Unlock()
-				return err
-			}
-
-			// set values
-			m.val, _ = m.opts.Reader.Values(set)
-			m.snap = &loader.Snapshot{
-				ChangeSet: set,
-				Version:   genVer(),
+			// a change set which can't be merged is skipped, the previous snapshot stays live
+			if err = m.set(e, cs); err != nil {
+				slog.Warn("memory.set() failed.", slog.String("source", s.String()), slog.Any("err", err))
 			}
-			m.Unlock()
-
-			// send watch updates
-			m.update()
 		}
 	}
 
+	// the first read failed, the source is read again until it's available
+	if !m.retry(e) {
+		return
+	}
+
 	for {
 		// get watcher
 		w, err := s.Watch()
 		if err != nil {
 			slog.Warn("memory.watch() failed.", slog.Any("err", err))
-			time.Sleep(time.Second)
+			select {
+			case <-m.exit:
+				return
+			case <-e.stop:
+				return
+			case <-time.After(time.Second):
+			}
 			continue
 		}
 
@@ -97,15 +135,16 @@ func (m *memory) watch(idx int, s source.Source) {
 			select {
 			case <-done:
 			case <-m.exit:
+			case <-e.stop:
 			}
 
-			if err = w.Stop(); err != nil {
+			if err := w.Stop(); err != nil {
 				slog.Error("Stop failed: ", slog.Any("err", err))
 			}
 		}()
 
 		// block watch
-		if err = watch(idx, w); err != nil {
+		if err = watch(w); err != nil {
 			// do something better
 			time.Sleep(time.Second)
 		}
@@ -113,15 +152,87 @@ func (m *memory) watch(idx int, s source.Source) {
 		// close done chan
 		close(done)
 
-		// if the config is closed exit
+		// if the config is closed or the source unloaded exit
 		select {
 		case <-m.exit:
 			return
+		case <-e.stop:
+			return
 		default:
 		}
 	}
 }
 
+// set saves the change set of the entry and merges it, nothing happens if the entry is unloaded
+func (m *memory) set(e *entry, cs *source.ChangeSet) error {
+	m.Lock()
+
+	// unloaded meanwhile
+	if e.stopped() {
+		m.Unlock()
+		return nil
+	}
+
+	// save, the previous set is put back if the new one can't be merged
+	prev := e.set
+	e.set = cs
+
+	// merge sets
+	set, prov, err := m.merge(m.entries)
+	if err != nil {
+		e.set = prev
+		m.Unlock()
+		return err
+	}
+	set.Source = e.source.String()
+
+	// set values, e.g. an ENC[...] value which can't be decrypted fails
+	val, err := m.opts.Reader.Values(set)
+	if err != nil {
+		e.set = prev
+		m.Unlock()
+		return err
+	}
+	changed := m.commit(set, val, prov, sets(m.entries))
+	m.Unlock()
+
+	// send watch updates
+	if changed {
+		m.update()
+	}
+
+	return nil
+}
+
+// retry reads the source of the entry until it succeeds, false if the loader is closed or the source unloaded
+func (m *memory) retry(e *entry) bool {
+	for {
+		m.RLock()
+		read := e.set != nil
+		m.RUnlock()
+		if read {
+			return true
+		}
+
+		select {
+		case <-m.exit:
+			return false
+		case <-e.stop:
+			return false
+		case <-time.After(RetryInterval):
+		}
+
+		cs, err := e.source.Read()
+		if err != nil {
+			continue
+		}
+
+		if err = m.set(e, cs); err != nil {
+			slog.Warn("memory.retry() failed.", slog.Any("err", err))
+		}
+	}
+}
+
 func (m *memory) loaded() bool {
 	var loaded bool
 	m.RLock()
@@ -132,32 +243,142 @@ func (m *memory) loaded() bool {
 	return loaded
 }
 
-// reload reads the sets and creates new values
-func (m *memory) reload() error {
+// reload reads the sets and creates new values, src names the sources which triggered it
+func (m *memory) reload(src ...string) error {
 	m.Lock()
 
 	// merge sets
-	set, err := m.opts.Reader.Merge(m.sets...)
+	set, prov, err := m.merge(m.entries)
 	if err != nil {
 		m.Unlock()
 		return err
 	}
+	set.Source = m.String()
+	if len(src) > 0 {
+		set.Source = strings.Join(src, ",")
+	}
 
-	// set values
-	m.val, _ = m.opts.Reader.Values(set)
-	m.snap = &loader.Snapshot{
-		ChangeSet: set,
-		Version:   genVer(),
+	// set values, the previous snapshot is kept on error
+	val, err := m.opts.Reader.Values(set)
+	if err != nil {
+		m.Unlock()
+		return err
 	}
+	changed := m.commit(set, val, prov, sets(m.entries))
 
 	m.Unlock()
 
 	// update watchers
-	m.update()
+	if changed {
+		m.update()
+	}
 
 	return nil
 }
 
+// merge merges the sets from the lowest layer to the highest one, then the overrides.
+// The provenance is traced if the reader supports it, m must be locked.
+func (m *memory) merge(entries []*entry) (*source.ChangeSet, reader.Provenance, error) {
+	sorted := append([]*entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].layer < sorted[j].layer
+	})
+
+	ordered := make([]*source.ChangeSet, 0, len(sorted)+1)
+	for _, e := range sorted {
+		if e.set != nil {
+			ordered = append(ordered, e.set)
+		}
+	}
+	if o := m.overrideSet(); o != nil {
+		ordered = append(ordered, o)
+	}
+
+	if t, ok := m.opts.Reader.(reader.Tracer); ok {
+		return t.MergeTrace(ordered...)
+	}
+
+	set, err := m.opts.Reader.Merge(ordered...)
+	return set, nil, err
+}
+
+// sets returns the change sets of the entries in load order, the sources never read are left out
+func sets(entries []*entry) []*source.ChangeSet {
+	res := make([]*source.ChangeSet, 0, len(entries))
+	for _, e := range entries {
+		if e.set != nil {
+			res = append(res, e.set)
+		}
+	}
+	return res
+}
+
+// newEntry creates the entry of a loaded source
+func (m *memory) newEntry(s source.Source, set *source.ChangeSet) *entry {
+	return &entry{
+		source: s,
+		set:    set,
+		layer:  m.layer(s),
+		stop:   make(chan bool),
+	}
+}
+
+// layer returns the layer of a source, LayerFile if it has none
+func (m *memory) layer(s source.Source) loader.Layer {
+	if l, ok := s.(loader.Layered); ok {
+		return l.Layer()
+	}
+	if l, ok := m.layers[s.String()]; ok {
+		return l
+	}
+	return loader.LayerFile
+}
+
+// commit makes the merged set the current snapshot, m must be locked.
+// If the content didn't change the version is kept and false is returned, so nobody is notified.
+func (m *memory) commit(set *source.ChangeSet, val reader.Values, prov reader.Provenance, sources []*source.ChangeSet) bool {
+	m.val = val
+
+	// the values are marshaled with sorted keys, unlike the merged set
+	content := set.Data
+	if val != nil {
+		content = val.Bytes()
+	}
+
+	snap := &loader.Snapshot{
+		ChangeSet:  set,
+		Hash:       loader.Hash(content),
+		Provenance: prov,
+		Sources:    sources,
+	}
+
+	if m.snap != nil && m.snap.Hash == snap.Hash {
+		snap.Seq, snap.Version = m.snap.Seq, m.snap.Version
+		m.snap = snap
+		return false
+	}
+
+	m.seq++
+	snap.Seq = m.seq
+	snap.Version = version(m.seq, snap.Hash)
+	m.push(snap)
+	return true
+}
+
+// push makes snap the current snapshot and records it in the history, m must be locked
+func (m *memory) push(snap *loader.Snapshot) {
+	m.snap = snap
+
+	if m.size <= 0 {
+		return
+	}
+
+	m.history = append(m.history, snap)
+	if len(m.history) > m.size {
+		m.history = append(m.history[:0], m.history[len(m.history)-m.size:]...)
+	}
+}
+
 func (m *memory) update() {
 	watchers := make([]*watcher, 0, m.watchers.Len())
 
@@ -171,13 +392,23 @@ func (m *memory) update() {
 	m.RUnlock()
 
 	for _, w := range watchers {
-		if w.version >= snap.Version {
+		if w.seq >= snap.Seq {
 			continue
 		}
 
+		var prov reader.Provenance
+		if snap.Provenance != nil {
+			prov = snap.Provenance.Filter(w.path...)
+		}
+
 		uv := updateValue{
-			version: m.snap.Version,
-			value:   val.Get(w.path...),
+			seq:        snap.Seq,
+			version:    snap.Version,
+			hash:       snap.Hash,
+			source:     snap.ChangeSet.Source,
+			value:      val.Get(w.path...),
+			provenance: prov,
+			sources:    loader.Copy(snap).Sources,
 		}
 
 		select {
@@ -212,28 +443,29 @@ func (m *memory) Snapshot() (*loader.Snapshot, error) {
 // Sync loads all the sources, calls the parser and updates the config
 func (m *memory) Sync() error {
 	//nolint:prealloc
-	var sets []*source.ChangeSet
+	var read []*entry
 
 	m.Lock()
 
 	// read the s
 	var gErr []string
 
-	for _, s := range m.sources {
-		ch, err := s.Read()
+	for _, e := range m.entries {
+		ch, err := e.source.Read()
 		if err != nil {
 			gErr = append(gErr, err.Error())
 			continue
 		}
-		sets = append(sets, ch)
+		read = append(read, &entry{source: e.source, set: ch, layer: e.layer})
 	}
 
 	// merge sets
-	set, err := m.opts.Reader.Merge(sets...)
+	set, prov, err := m.merge(read)
 	if err != nil {
 		m.Unlock()
 		return err
 	}
+	set.Source = m.String()
 
 	// set values
 	var val reader.Values
@@ -242,16 +474,14 @@ func (m *memory) Sync() error {
 		m.Unlock()
 		return err
 	}
-	m.val = val
-	m.snap = &loader.Snapshot{
-		ChangeSet: set,
-		Version:   genVer(),
-	}
+	changed := m.commit(set, val, prov, sets(read))
 
 	m.Unlock()
 
 	// update watchers
-	m.update()
+	if changed {
+		m.update()
+	}
 
 	if len(gErr) > 0 {
 		return fmt.Errorf("loading errors: %s", strings.Join(gErr, "\n"))
@@ -306,25 +536,26 @@ func (m *memory) Get(path ...string) (reader.Value, error) {
 }
 
 // Load 加载数据源
+// A source which can not be read is still loaded, it's read again in the background until it's available.
 func (m *memory) Load(sources ...source.Source) error {
 	var gErr []string
+	var names []string
 
 	for _, s := range sources {
 		set, err := s.Read()
 		if err != nil {
 			gErr = append(gErr, fmt.Sprintf("loading %s error: %v", s, err))
-			// continue processing
-			continue
+		} else {
+			names = append(names, s.String())
 		}
+		e := m.newEntry(s, set)
 		m.Lock()
-		m.sources = append(m.sources, s)
-		m.sets = append(m.sets, set)
-		idx := len(m.sets) - 1
+		m.entries = append(m.entries, e)
 		m.Unlock()
-		go m.watch(idx, s)
+		go m.watch(e)
 	}
 
-	if err := m.reload(); err != nil {
+	if err := m.reload(names...); err != nil {
 		gErr = append(gErr, err.Error())
 	}
 
@@ -335,6 +566,124 @@ func (m *memory) Load(sources ...source.Source) error {
 	return nil
 }
 
+// Unload stops watching the sources named name and drops their change sets
+func (m *memory) Unload(name string) error {
+	m.Lock()
+	entries := make([]*entry, 0, len(m.entries))
+	removed := map[int]*entry{}
+	for i, e := range m.entries {
+		if e.source.String() == name {
+			close(e.stop)
+			removed[i] = e
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) == len(m.entries) {
+		m.Unlock()
+		return fmt.Errorf("source %s is not loaded", name)
+	}
+
+	m.entries = entries
+	m.undo = func() error {
+		m.Lock()
+		m.entries = m.restore(m.entries, removed)
+		m.Unlock()
+		return m.reload(name)
+	}
+	m.Unlock()
+
+	return m.reload(name)
+}
+
+// Replace swaps the sources named name for s, which takes the place of the first one.
+// Nothing changes if s can not be read.
+func (m *memory) Replace(name string, s source.Source) error {
+	set, err := s.Read()
+	if err != nil {
+		return errors.Wrapf(err, "loading %s error", s)
+	}
+
+	e := m.newEntry(s, set)
+	replaced := false
+
+	m.Lock()
+	entries := make([]*entry, 0, len(m.entries))
+	removed := map[int]*entry{}
+	for i, old := range m.entries {
+		if old.source.String() != name {
+			entries = append(entries, old)
+			continue
+		}
+
+		close(old.stop)
+		removed[i] = old
+		if !replaced {
+			entries = append(entries, e)
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		m.Unlock()
+		return fmt.Errorf("source %s is not loaded", name)
+	}
+
+	m.entries = entries
+	m.undo = func() error {
+		m.Lock()
+		close(e.stop)
+		kept := make([]*entry, 0, len(m.entries))
+		for _, v := range m.entries {
+			if v != e {
+				kept = append(kept, v)
+			}
+		}
+		m.entries = m.restore(kept, removed)
+		m.Unlock()
+		return m.reload(name)
+	}
+	m.Unlock()
+
+	go m.watch(e)
+
+	return m.reload(s.String())
+}
+
+// restore puts new entries of the removed ones back at their indices and watches them again, m must be locked
+func (m *memory) restore(entries []*entry, removed map[int]*entry) []*entry {
+	indices := make([]int, 0, len(removed))
+	for i := range removed {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	for _, i := range indices {
+		e := m.newEntry(removed[i].source, removed[i].set)
+		if i > len(entries) {
+			i = len(entries)
+		}
+		entries = append(entries[:i], append([]*entry{e}, entries[i:]...)...)
+		go m.watch(e)
+	}
+	return entries
+}
+
+// Revert undoes the last Override, RemoveOverride, Unload, Replace or Rollback.
+// The source changes since are kept, only the change itself is undone.
+func (m *memory) Revert() error {
+	m.Lock()
+	undo := m.undo
+	m.undo = nil
+	m.Unlock()
+
+	if undo == nil {
+		return errors.New("nothing to revert")
+	}
+	return undo()
+}
+
 // Watch 监听路径
 func (m *memory) Watch(path ...string) (loader.Watcher, error) {
 	value, err := m.Get(path...)
@@ -350,6 +699,7 @@ func (m *memory) Watch(path ...string) (loader.Watcher, error) {
 		value:   value,
 		reader:  m.opts.Reader,
 		updates: make(chan updateValue, 1),
+		seq:     m.snap.Seq,
 		version: m.snap.Version,
 	}
 
@@ -367,6 +717,71 @@ func (m *memory) Watch(path ...string) (loader.Watcher, error) {
 	return w, nil
 }
 
+// History returns copies of the latest snapshots, oldest first
+func (m *memory) History() []*loader.Snapshot {
+	m.RLock()
+	defer m.RUnlock()
+
+	snaps := make([]*loader.Snapshot, 0, len(m.history))
+	for _, snap := range m.history {
+		snaps = append(snaps, loader.Copy(snap))
+	}
+	return snaps
+}
+
+// Rollback makes the merged ChangeSet of an earlier snapshot current again under a new version.
+// It stays current until the next change of any source, nothing happens if the content is the same.
+func (m *memory) Rollback(version string) error {
+	m.Lock()
+
+	var target *loader.Snapshot
+	for _, snap := range m.history {
+		if snap.Version == version {
+			target = snap
+			break
+		}
+	}
+
+	if target == nil {
+		m.Unlock()
+		return fmt.Errorf("snapshot %s not found in history", version)
+	}
+
+	cs := *target.ChangeSet
+	val, err := m.opts.Reader.Values(&cs)
+	if err != nil {
+		m.Unlock()
+		return err
+	}
+
+	prev := m.snap
+	m.undo = func() error {
+		m.Lock()
+		val, err := m.opts.Reader.Values(prev.ChangeSet)
+		if err != nil {
+			m.Unlock()
+			return err
+		}
+		changed := m.commit(prev.ChangeSet, val, prev.Provenance, prev.Sources)
+		m.Unlock()
+
+		if changed {
+			m.update()
+		}
+		return nil
+	}
+
+	changed := m.commit(&cs, val, target.Provenance, target.Sources)
+	m.Unlock()
+
+	// update watchers
+	if changed {
+		m.update()
+	}
+
+	return nil
+}
+
 // String memory
 func (m *memory) String() string {
 	return "memory"
@@ -374,22 +789,26 @@ func (m *memory) String() string {
 
 // Next 下一个变更的快照
 func (w *watcher) Next() (*loader.Snapshot, error) {
-	update := func(v reader.Value) *loader.Snapshot {
+	update := func(uv updateValue) *loader.Snapshot {
+		v := uv.value
 		w.value = v
 
 		cs := &source.ChangeSet{
 			Data:      v.Bytes(),
 			Format:    w.reader.String(),
-			Source:    "memory",
+			Source:    uv.source,
 			Timestamp: time.Now(),
 		}
-		cs.Checksum = cs.Sum()
+		cs.Checksum, _ = cs.Sum()
 
 		return &loader.Snapshot{
-			ChangeSet: cs,
-			Version:   w.version,
+			ChangeSet:  cs,
+			Version:    w.version,
+			Seq:        w.seq,
+			Hash:       uv.hash,
+			Provenance: uv.provenance,
+			Sources:    uv.sources,
 		}
-
 	}
 
 	for {
@@ -398,19 +817,18 @@ func (w *watcher) Next() (*loader.Snapshot, error) {
 			return nil, errors.New("watcher stopped")
 
 		case uv := <-w.updates:
-			if uv.version <= w.version {
+			if uv.seq <= w.seq {
 				continue
 			}
 
-			v := uv.value
-
+			w.seq = uv.seq
 			w.version = uv.version
 
-			if bytes.Equal(w.value.Bytes(), v.Bytes()) {
+			if bytes.Equal(w.value.Bytes(), uv.value.Bytes()) {
 				continue
 			}
 
-			return update(v), nil
+			return update(uv), nil
 		}
 	}
 }
@@ -427,8 +845,12 @@ func (w *watcher) Stop() error {
 	return nil
 }
 
-func genVer() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+// version formats the sequence number with a short content hash
+func version(seq uint64, hash string) string {
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return fmt.Sprintf("%d-%s", seq, hash)
 }
 
 // NewLoader memory配置加载
@@ -445,14 +867,22 @@ func NewLoader(opts ...loader.Option) loader.Loader {
 		exit:     make(chan bool),
 		opts:     options,
 		watchers: list.New(),
-		sources:  options.Source,
+		size:     DefaultHistory,
 	}
 
-	m.sets = make([]*source.ChangeSet, len(options.Source))
+	if options.Context != nil {
+		if n, ok := options.Context.Value(historyKey{}).(int); ok {
+			m.size = n
+		}
+		if l, ok := options.Context.Value(layersKey{}).(map[string]loader.Layer); ok {
+			m.layers = l
+		}
+	}
 
-	for i, s := range options.Source {
-		m.sets[i] = &source.ChangeSet{Source: s.String()}
-		go m.watch(i, s)
+	for _, s := range options.Source {
+		e := m.newEntry(s, &source.ChangeSet{Source: s.String()})
+		m.entries = append(m.entries, e)
+		go m.watch(e)
 	}
 
 	return m
@@ -4,7 +4,10 @@ import (
 	"container/list"
 	"errors"
 	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/reader"
 	"github.com/nextpkg/nextcfg/reader/json"
+	"github.com/nextpkg/nextcfg/secrets"
+	"github.com/nextpkg/nextcfg/secrets/secretbox"
 	"github.com/nextpkg/nextcfg/source"
 	"github.com/smartystreets/goconvey/convey"
 	"sync"
@@ -80,7 +83,7 @@ func (s *mockSource) Update(c *source.ChangeSet) {
 		Source:    "mock",
 		Timestamp: time.Now(),
 	}
-	s.ChangeSet.Checksum = s.ChangeSet.Sum()
+	s.ChangeSet.Checksum, _ = s.ChangeSet.Sum()
 
 	for _, w := range s.Watchers {
 		select {
@@ -114,8 +117,7 @@ func TestDoWatch(t *testing.T) {
 				Source: []source.Source{src},
 			},
 			watchers: list.New(),
-			sources:  []source.Source{src},
-			sets:     []*source.ChangeSet{cs},
+			entries:  []*entry{{source: src, set: cs, stop: make(chan bool)}},
 		}
 
 		convey.So(m.loaded(), convey.ShouldBeFalse)
@@ -135,3 +137,335 @@ func TestDoWatch(t *testing.T) {
 	})
 
 }
+
+func TestHistory(t *testing.T) {
+	convey.Convey("history and rollback", t, func() {
+		src := &mockSource{
+			Watchers: make(map[string]*mockWatcher),
+			ChangeSet: &source.ChangeSet{
+				Data:   []byte(`{"foo": "v0"}`),
+				Format: "json",
+			},
+		}
+
+		m := NewLoader(WithHistory(2))
+		convey.So(m.Load(src), convey.ShouldBeNil)
+
+		for _, v := range []string{"v1", "v2"} {
+			// change without notifying the watchers
+			src.Lock()
+			src.ChangeSet = &source.ChangeSet{Data: []byte(`{"foo": "` + v + `"}`), Format: "json"}
+			src.Unlock()
+			convey.So(m.Sync(), convey.ShouldBeNil)
+			time.Sleep(time.Millisecond)
+		}
+
+		history := m.History()
+		convey.So(len(history), convey.ShouldEqual, 2)
+
+		v, err := m.(*memory).Get("foo")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v.String(""), convey.ShouldEqual, "v2")
+
+		convey.So(m.Rollback("unknown"), convey.ShouldNotBeNil)
+		convey.So(m.Rollback(history[0].Version), convey.ShouldBeNil)
+
+		v, err = m.(*memory).Get("foo")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v.String(""), convey.ShouldEqual, "v1")
+
+		snap, err := m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(snap.Seq, convey.ShouldBeGreaterThan, history[1].Seq)
+		convey.So(len(m.History()), convey.ShouldEqual, 2)
+
+		convey.So(m.Close(), convey.ShouldBeNil)
+	})
+}
+
+func TestVersion(t *testing.T) {
+	convey.Convey("versions only change with the content", t, func() {
+		src := &mockSource{
+			Watchers:  make(map[string]*mockWatcher),
+			ChangeSet: &source.ChangeSet{Data: []byte(`{"a": 1, "b": 2}`), Format: "json"},
+		}
+
+		m := NewLoader()
+		convey.So(m.Load(src), convey.ShouldBeNil)
+
+		first, err := m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(first.Seq, convey.ShouldEqual, 1)
+		convey.So(first.Version, convey.ShouldStartWith, "1-")
+
+		// same tree with another key order
+		src.Lock()
+		src.ChangeSet = &source.ChangeSet{Data: []byte(`{"b": 2, "a": 1}`), Format: "json"}
+		src.Unlock()
+		convey.So(m.Sync(), convey.ShouldBeNil)
+
+		snap, err := m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(snap.Version, convey.ShouldEqual, first.Version)
+		convey.So(snap.Hash, convey.ShouldEqual, first.Hash)
+		convey.So(len(m.History()), convey.ShouldEqual, 1)
+
+		src.Lock()
+		src.ChangeSet = &source.ChangeSet{Data: []byte(`{"a": 1, "b": 3}`), Format: "json"}
+		src.Unlock()
+		convey.So(m.Sync(), convey.ShouldBeNil)
+
+		snap, err = m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(snap.Seq, convey.ShouldEqual, 2)
+		convey.So(snap.Newer(first), convey.ShouldBeTrue)
+		convey.So(snap.Hash, convey.ShouldNotEqual, first.Hash)
+
+		convey.So(m.Close(), convey.ShouldBeNil)
+	})
+}
+
+func TestLayer(t *testing.T) {
+	convey.Convey("sources are merged by layer", t, func() {
+		newSource := func(data string) *mockSource {
+			return &mockSource{
+				Watchers:  make(map[string]*mockWatcher),
+				ChangeSet: &source.ChangeSet{Data: []byte(data), Format: "json"},
+			}
+		}
+
+		m := NewLoader()
+		convey.So(m.Load(
+			loader.InLayer(loader.LayerEnv, newSource(`{"foo": "env"}`)),
+			newSource(`{"foo": "file", "bar": "file"}`),
+		), convey.ShouldBeNil)
+
+		v, err := m.(*memory).Get("foo")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v.String(""), convey.ShouldEqual, "env")
+
+		m = NewLoader(WithLayer("mock", loader.LayerFlag))
+		convey.So(m.Load(
+			newSource(`{"foo": "flag"}`),
+			loader.InLayer(loader.LayerEnv, newSource(`{"foo": "env"}`)),
+		), convey.ShouldBeNil)
+
+		v, err = m.(*memory).Get("foo")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v.String(""), convey.ShouldEqual, "flag")
+		convey.So(loader.LayerOverride.String(), convey.ShouldEqual, "override")
+	})
+}
+
+func TestOverride(t *testing.T) {
+	convey.Convey("overrides survive reloads until they expire", t, func() {
+		src := &mockSource{
+			Watchers:  make(map[string]*mockWatcher),
+			ChangeSet: &source.ChangeSet{Data: []byte(`{"db": {"host": "localhost"}, "debug": false}`), Format: "json"},
+		}
+
+		m := NewLoader()
+		convey.So(m.Load(src), convey.ShouldBeNil)
+		convey.So(m.Override(loader.Override{}), convey.ShouldNotBeNil)
+
+		convey.So(m.Override(loader.Override{Path: []string{"db", "host"}, Value: "mysql", Who: "alice", Why: "failover"}), convey.ShouldBeNil)
+		convey.So(m.Override(loader.Override{Path: []string{"debug"}, Value: true, Who: "bob", TTL: 50 * time.Millisecond}), convey.ShouldBeNil)
+
+		get := func(path ...string) string {
+			v, err := m.(*memory).Get(path...)
+			convey.So(err, convey.ShouldBeNil)
+			return string(v.Bytes())
+		}
+
+		// reload of the source keeps the overrides
+		convey.So(m.Sync(), convey.ShouldBeNil)
+		convey.So(get("db", "host"), convey.ShouldEqual, "mysql")
+		convey.So(get("debug"), convey.ShouldEqual, "true")
+		convey.So(len(m.Overrides()), convey.ShouldEqual, 2)
+
+		snap, err := m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(snap.Provenance["db.host"].Source, convey.ShouldEqual, "override")
+
+		time.Sleep(200 * time.Millisecond)
+		convey.So(get("debug"), convey.ShouldEqual, "false")
+
+		convey.So(m.RemoveOverride("carol", "unknown"), convey.ShouldNotBeNil)
+		convey.So(m.RemoveOverride("carol", "db", "host"), convey.ShouldBeNil)
+		convey.So(get("db", "host"), convey.ShouldEqual, "localhost")
+		convey.So(len(m.Overrides()), convey.ShouldEqual, 0)
+
+		var actions []loader.AuditAction
+		for _, e := range m.Audit() {
+			actions = append(actions, e.Action)
+		}
+		convey.So(actions, convey.ShouldResemble, []loader.AuditAction{
+			loader.AuditSet, loader.AuditSet, loader.AuditExpire, loader.AuditRemove,
+		})
+		convey.So(m.Audit()[3].Who, convey.ShouldEqual, "carol")
+		convey.So(m.Audit()[3].Override.Why, convey.ShouldEqual, "failover")
+	})
+}
+
+type namedSource struct {
+	*mockSource
+	name string
+}
+
+func (s *namedSource) String() string {
+	return s.name
+}
+
+func TestUnloadReplace(t *testing.T) {
+	convey.Convey("sources can be unloaded and replaced", t, func() {
+		newSource := func(name, data string) *namedSource {
+			return &namedSource{
+				mockSource: &mockSource{
+					Watchers:  make(map[string]*mockWatcher),
+					ChangeSet: &source.ChangeSet{Data: []byte(data), Format: "json"},
+				},
+				name: name,
+			}
+		}
+		get := func(m loader.Loader, path ...string) string {
+			v, err := m.(*memory).Get(path...)
+			convey.So(err, convey.ShouldBeNil)
+			return v.String("")
+		}
+
+		file := newSource("file", `{"host": "file", "port": "80"}`)
+		env := newSource("env", `{"host": "env"}`)
+
+		m := NewLoader()
+		convey.So(m.Load(file, env), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "env")
+
+		// wait for the source watchers to start
+		time.Sleep(100 * time.Millisecond)
+
+		convey.So(m.Unload("unknown"), convey.ShouldNotBeNil)
+		convey.So(m.Unload("env"), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "file")
+
+		// the watcher of the unloaded source is stopped
+		time.Sleep(100 * time.Millisecond)
+		env.RLock()
+		convey.So(len(env.Watchers), convey.ShouldEqual, 0)
+		env.RUnlock()
+
+		consul := newSource("consul", `{"host": "consul"}`)
+		convey.So(m.Replace("unknown", consul), convey.ShouldNotBeNil)
+		convey.So(m.Replace("file", &namedSource{mockSource: &mockSource{}, name: "broken"}), convey.ShouldNotBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "file")
+
+		convey.So(m.Replace("file", consul), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "consul")
+		convey.So(get(m, "port"), convey.ShouldEqual, "")
+
+		// changes of the replaced source are ignored
+		file.Update(&source.ChangeSet{Data: []byte(`{"host": "file2"}`), Format: "json"})
+		time.Sleep(100 * time.Millisecond)
+		convey.So(get(m, "host"), convey.ShouldEqual, "consul")
+
+		convey.So(m.Close(), convey.ShouldBeNil)
+	})
+}
+
+func TestRevert(t *testing.T) {
+	convey.Convey("the last change is reverted", t, func() {
+		newSource := func(name, data string) *namedSource {
+			return &namedSource{
+				mockSource: &mockSource{
+					Watchers:  make(map[string]*mockWatcher),
+					ChangeSet: &source.ChangeSet{Data: []byte(data), Format: "json"},
+				},
+				name: name,
+			}
+		}
+		get := func(m loader.Loader, path ...string) string {
+			v, err := m.(*memory).Get(path...)
+			convey.So(err, convey.ShouldBeNil)
+			return v.String("")
+		}
+
+		file := newSource("file", `{"host": "file"}`)
+		env := newSource("env", `{"host": "env"}`)
+
+		m := NewLoader()
+		convey.So(m.Load(file, env), convey.ShouldBeNil)
+		convey.So(m.Revert(), convey.ShouldNotBeNil)
+
+		convey.So(m.Override(loader.Override{Path: []string{"host"}, Value: "a", Who: "alice"}), convey.ShouldBeNil)
+		convey.So(m.Override(loader.Override{Path: []string{"host"}, Value: "b", Who: "bob"}), convey.ShouldBeNil)
+		convey.So(m.Revert(), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "a")
+		convey.So(m.Overrides()[0].Who, convey.ShouldEqual, "alice")
+		convey.So(m.Audit()[1].Rejected, convey.ShouldBeTrue)
+		convey.So(m.Revert(), convey.ShouldNotBeNil)
+
+		convey.So(m.RemoveOverride("carol", "host"), convey.ShouldBeNil)
+		convey.So(m.Revert(), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "a")
+		convey.So(m.Audit()[2].Rejected, convey.ShouldBeTrue)
+		convey.So(m.RemoveOverride("carol", "host"), convey.ShouldBeNil)
+
+		// wait for the source watchers to start
+		time.Sleep(100 * time.Millisecond)
+
+		convey.So(m.Unload("env"), convey.ShouldBeNil)
+		convey.So(m.Revert(), convey.ShouldBeNil)
+		convey.So(get(m, "host"), convey.ShouldEqual, "env")
+
+		convey.So(m.Replace("file", newSource("consul", `{"port": "80"}`)), convey.ShouldBeNil)
+		convey.So(m.Revert(), convey.ShouldBeNil)
+		convey.So(get(m, "port"), convey.ShouldEqual, "")
+
+		// the restored sources are watched again
+		time.Sleep(100 * time.Millisecond)
+		env.Update(&source.ChangeSet{Data: []byte(`{"host": "env2"}`), Format: "json"})
+		time.Sleep(100 * time.Millisecond)
+		convey.So(get(m, "host"), convey.ShouldEqual, "env2")
+
+		convey.So(m.Close(), convey.ShouldBeNil)
+	})
+}
+
+func TestUndecryptable(t *testing.T) {
+	convey.Convey("a value which can't be decrypted keeps the previous snapshot", t, func() {
+		key := make([]byte, 32)
+		sb := secretbox.NewSecrets()
+		convey.So(sb.Init(secrets.Key(key)), convey.ShouldBeNil)
+
+		src := &mockSource{
+			Watchers:  make(map[string]*mockWatcher),
+			ChangeSet: &source.ChangeSet{Data: []byte(`{"a": "b"}`), Format: "json"},
+		}
+
+		m := NewLoader(WithReader(json.NewReader(reader.WithSecrets("secretbox", sb))))
+		convey.So(m.Load(src), convey.ShouldBeNil)
+
+		w, err := m.Watch("a")
+		convey.So(err, convey.ShouldBeNil)
+
+		// wait for the source watcher to start
+		time.Sleep(100 * time.Millisecond)
+		src.Update(&source.ChangeSet{Data: []byte(`{"a": "ENC[secretbox,AAAA]"}`), Format: "json"})
+		time.Sleep(100 * time.Millisecond)
+
+		snap, err := m.Snapshot()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(snap.Seq, convey.ShouldEqual, 1)
+		convey.So(string(snap.ChangeSet.Data), convey.ShouldEqual, `{"a":"b"}`)
+
+		// the next good one is applied
+		src.Update(&source.ChangeSet{Data: []byte(`{"a": "c"}`), Format: "json"})
+
+		next, err := w.Next()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(next.Seq, convey.ShouldEqual, 2)
+		convey.So(string(next.ChangeSet.Data), convey.ShouldEqual, "c")
+
+		convey.So(w.Stop(), convey.ShouldBeNil)
+		convey.So(m.Close(), convey.ShouldBeNil)
+	})
+}
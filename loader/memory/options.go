@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"context"
+
 	"github.com/nextpkg/nextcfg/loader"
 	"github.com/nextpkg/nextcfg/reader"
 	"github.com/nextpkg/nextcfg/source"
@@ -19,3 +21,33 @@ func WithReader(r reader.Reader) loader.Option {
 		o.Reader = r
 	}
 }
+
+type historyKey struct{}
+
+// WithHistory sets the number of snapshots kept for rollback, 0 disables the history
+func WithHistory(n int) loader.Option {
+	return func(o *loader.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, historyKey{}, n)
+	}
+}
+
+type layersKey struct{}
+
+// WithLayer puts the sources named name in the layer, unless they are wrapped by loader.InLayer
+func WithLayer(name string, layer loader.Layer) loader.Option {
+	return func(o *loader.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		layers, _ := o.Context.Value(layersKey{}).(map[string]loader.Layer)
+		merged := make(map[string]loader.Layer, len(layers)+1)
+		for k, v := range layers {
+			merged[k] = v
+		}
+		merged[name] = layer
+		o.Context = context.WithValue(o.Context, layersKey{}, merged)
+	}
+}
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mohae/deepcopy"
+	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
+)
+
+// DefaultAudit is the number of audit entries kept
+var DefaultAudit = 100
+
+const overrideSource = "override"
+
+// Override sets a runtime value above all the sources, an override of the same path is replaced
+func (m *memory) Override(o loader.Override) error {
+	if len(o.Path) == 0 {
+		return errors.New("override path is empty")
+	}
+	if o.At.IsZero() {
+		o.At = time.Now()
+	}
+	o.Path = append([]string(nil), o.Path...)
+	o.Value = deepcopy.Copy(o.Value)
+
+	m.Lock()
+	overrides := make([]loader.Override, 0, len(m.overrides)+1)
+	prev, at := -1, 0
+	for i, v := range m.overrides {
+		if v.Key() != o.Key() {
+			overrides = append(overrides, v)
+			continue
+		}
+		prev, at = i, len(overrides)
+	}
+
+	// the replaced override takes its place again
+	var replaced *loader.Override
+	if prev >= 0 {
+		replaced = &m.overrides[prev]
+	}
+	m.undo = func() error {
+		m.Lock()
+		m.overrides = without(m.overrides, o)
+		if replaced != nil && !replaced.Expired(time.Now()) {
+			m.overrides = insert(m.overrides, at, *replaced)
+		}
+		m.reject(loader.AuditSet, o, o.At)
+		m.Unlock()
+		return m.reload(overrideSource)
+	}
+
+	m.overrides = append(overrides, o)
+	m.audit(loader.AuditEntry{Action: loader.AuditSet, Override: o, Who: o.Who, At: o.At})
+	m.Unlock()
+
+	if o.TTL > 0 {
+		time.AfterFunc(time.Until(o.At.Add(o.TTL)), m.expire)
+	}
+
+	return m.reload(overrideSource)
+}
+
+// RemoveOverride removes the override of the path
+func (m *memory) RemoveOverride(who string, path ...string) error {
+	key := strings.Join(path, ".")
+
+	m.Lock()
+	overrides := make([]loader.Override, 0, len(m.overrides))
+	var removed *loader.Override
+	for i, v := range m.overrides {
+		if v.Key() == key {
+			removed = &m.overrides[i]
+			continue
+		}
+		overrides = append(overrides, v)
+	}
+
+	if removed == nil {
+		m.Unlock()
+		return fmt.Errorf("no override of %s", key)
+	}
+
+	now := time.Now()
+	o := *removed
+	at := len(overrides)
+	for i, v := range m.overrides {
+		if v.Key() == key {
+			at = i
+		}
+	}
+	m.undo = func() error {
+		m.Lock()
+		// unless it expired or was set again meanwhile
+		if !o.Expired(time.Now()) && len(without(m.overrides, loader.Override{Path: o.Path})) == len(m.overrides) {
+			m.overrides = insert(m.overrides, at, o)
+		}
+		m.reject(loader.AuditRemove, o, now)
+		m.Unlock()
+		return m.reload(overrideSource)
+	}
+
+	m.audit(loader.AuditEntry{Action: loader.AuditRemove, Override: o, Who: who, At: now})
+	m.overrides = overrides
+	m.Unlock()
+
+	return m.reload(overrideSource)
+}
+
+// Overrides returns copies of the overrides in effect, oldest first
+func (m *memory) Overrides() []loader.Override {
+	m.RLock()
+	defer m.RUnlock()
+
+	overrides := make([]loader.Override, 0, len(m.overrides))
+	for _, o := range m.overrides {
+		o.Value = deepcopy.Copy(o.Value)
+		overrides = append(overrides, o)
+	}
+	return overrides
+}
+
+// Audit returns the audit trail of the overrides, oldest first
+func (m *memory) Audit() []loader.AuditEntry {
+	m.RLock()
+	defer m.RUnlock()
+
+	return append([]loader.AuditEntry(nil), m.audits...)
+}
+
+// expire removes the overrides whose TTL has elapsed
+func (m *memory) expire() {
+	select {
+	case <-m.exit:
+		return
+	default:
+	}
+
+	now := time.Now()
+
+	m.Lock()
+	overrides := make([]loader.Override, 0, len(m.overrides))
+	for _, o := range m.overrides {
+		if o.Expired(now) {
+			m.audit(loader.AuditEntry{Action: loader.AuditExpire, Override: o, At: now})
+			continue
+		}
+		overrides = append(overrides, o)
+	}
+	expired := len(overrides) != len(m.overrides)
+	m.overrides = overrides
+	m.Unlock()
+
+	if !expired {
+		return
+	}
+
+	if err := m.reload(overrideSource); err != nil {
+		slog.Error("expire overrides failed.", slog.Any("err", err))
+	}
+}
+
+// without returns the overrides but the one of the key of o, set at the time of o unless it's zero
+func without(overrides []loader.Override, o loader.Override) []loader.Override {
+	res := make([]loader.Override, 0, len(overrides))
+	for _, v := range overrides {
+		if v.Key() == o.Key() && (o.At.IsZero() || v.At.Equal(o.At)) {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+// insert puts o at index i of the overrides, at the end if there are fewer
+func insert(overrides []loader.Override, i int, o loader.Override) []loader.Override {
+	if i > len(overrides) {
+		i = len(overrides)
+	}
+	res := make([]loader.Override, 0, len(overrides)+1)
+	res = append(res, overrides[:i]...)
+	res = append(res, o)
+	return append(res, overrides[i:]...)
+}
+
+// reject marks the audit entry of a reverted change, m must be locked
+func (m *memory) reject(action loader.AuditAction, o loader.Override, at time.Time) {
+	for i := len(m.audits) - 1; i >= 0; i-- {
+		a := &m.audits[i]
+		if a.Action == action && a.Override.Key() == o.Key() && a.At.Equal(at) {
+			a.Rejected = true
+			return
+		}
+	}
+}
+
+// audit appends to the audit trail, m must be locked
+func (m *memory) audit(e loader.AuditEntry) {
+	m.audits = append(m.audits, e)
+	if len(m.audits) > DefaultAudit {
+		m.audits = append(m.audits[:0], m.audits[len(m.audits)-DefaultAudit:]...)
+	}
+}
+
+// overrideSet returns the overrides as a JSON merge patch above all the sets, m must be locked
+func (m *memory) overrideSet() *source.ChangeSet {
+	if len(m.overrides) == 0 {
+		return nil
+	}
+
+	patch := make(map[string]interface{})
+	var ts time.Time
+
+	for _, o := range m.overrides {
+		node := patch
+		for _, k := range o.Path[:len(o.Path)-1] {
+			child, ok := node[k].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[k] = child
+			}
+			node = child
+		}
+
+		if o.Delete {
+			node[o.Path[len(o.Path)-1]] = nil
+		} else {
+			node[o.Path[len(o.Path)-1]] = deepcopy.Copy(o.Value)
+		}
+
+		if o.At.After(ts) {
+			ts = o.At
+		}
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		slog.Error("encode overrides failed.", slog.Any("err", err))
+		return nil
+	}
+
+	cs := &source.ChangeSet{
+		Data:      data,
+		Format:    source.FormatMergePatch,
+		Source:    overrideSource,
+		Timestamp: ts,
+	}
+	cs.Checksum, _ = cs.Sum()
+
+	return cs
+}
@@ -0,0 +1,57 @@
+package loader
+
+import (
+	"strings"
+	"time"
+)
+
+// Override is a runtime value above all the sources, it survives the reloads until it expires or is removed
+type Override struct {
+	// Path of the key
+	Path []string `json:"path"`
+	// Value replaces the key, maps are merged like a JSON merge patch
+	Value interface{} `json:"value,omitempty"`
+	// Delete removes the key instead
+	Delete bool `json:"delete,omitempty"`
+	// Who set the override
+	Who string `json:"who,omitempty"`
+	// Why the override was set
+	Why string `json:"why,omitempty"`
+	// At is when the override was set, now if zero
+	At time.Time `json:"at"`
+	// TTL of the override, it never expires if zero
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// Key returns the dot separated key path
+func (o Override) Key() string {
+	return strings.Join(o.Path, ".")
+}
+
+// Expired reports whether the TTL of the override has elapsed at t
+func (o Override) Expired(t time.Time) bool {
+	return o.TTL > 0 && !t.Before(o.At.Add(o.TTL))
+}
+
+// AuditAction is what happened to an override
+type AuditAction string
+
+const (
+	// AuditSet the override was set or replaced
+	AuditSet AuditAction = "set"
+	// AuditRemove the override was removed
+	AuditRemove AuditAction = "remove"
+	// AuditExpire the TTL of the override elapsed
+	AuditExpire AuditAction = "expire"
+)
+
+// AuditEntry records a change of the overrides
+type AuditEntry struct {
+	Action   AuditAction `json:"action"`
+	Override Override    `json:"override"`
+	// Who removed the override, the setter is in Override
+	Who string    `json:"who,omitempty"`
+	At  time.Time `json:"at"`
+	// Rejected is set if the change was reverted because its snapshot was rejected
+	Rejected bool `json:"rejected,omitempty"`
+}
@@ -0,0 +1,54 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/nextpkg/nextcfg/encoder/json"
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// Save writes the snapshot to path atomically
+func Save(path string, s *Snapshot) error {
+	b, err := json.NewEncoder().Encode(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open reads a snapshot written by Save
+func Open(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Snapshot
+	if err = json.NewEncoder().Decode(b, &s); err != nil {
+		return nil, err
+	}
+
+	if s.ChangeSet == nil {
+		s.ChangeSet = &source.ChangeSet{}
+	}
+
+	return &s, nil
+}
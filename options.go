@@ -3,6 +3,7 @@ package nextcfg
 import (
 	"github.com/nextpkg/nextcfg/loader"
 	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/schema"
 	"github.com/nextpkg/nextcfg/source"
 )
 
@@ -26,3 +27,18 @@ func WithReader(r reader.Reader) Option {
 		o.Reader = r
 	}
 }
+
+// WithSnapshotFile persists the last known good snapshot to path,
+// so that a restart can boot from it while the sources are unavailable
+func WithSnapshotFile(path string) Option {
+	return func(o *Options) {
+		o.SnapshotFile = path
+	}
+}
+
+// WithSchema rejects the snapshots which are invalid against s, the previous one stays live
+func WithSchema(s *schema.Schema) Option {
+	return func(o *Options) {
+		o.Schema = s
+	}
+}
@@ -2,9 +2,9 @@ package json
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
-	"dario.cat/mergo"
 	"github.com/nextpkg/nextcfg/encoder"
 	"github.com/nextpkg/nextcfg/encoder/json"
 	"github.com/nextpkg/nextcfg/reader"
@@ -18,7 +18,14 @@ type jsonReader struct {
 
 // Merge 合并配置变更列表
 func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, error) {
+	cs, _, err := j.MergeTrace(changes...)
+	return cs, err
+}
+
+// MergeTrace 合并配置变更列表，并记录每个key的来源
+func (j *jsonReader) MergeTrace(changes ...*source.ChangeSet) (*source.ChangeSet, reader.Provenance, error) {
 	var merged map[string]interface{}
+	mg := &merger{opts: j.opts, prov: make(reader.Provenance)}
 
 	for _, m := range changes {
 		if m == nil {
@@ -29,6 +36,35 @@ func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, err
 			continue
 		}
 
+		if merged == nil {
+			merged = make(map[string]interface{})
+		}
+
+		o := reader.Origin{
+			Source:    m.Source,
+			Checksum:  m.Checksum,
+			Timestamp: m.Timestamp,
+		}
+
+		switch m.Format {
+		case source.FormatMergePatch:
+			var patch map[string]interface{}
+			if err := j.json.Decode(m.Data, &patch); err != nil {
+				return nil, nil, err
+			}
+			mg.mergePatch(merged, patch, nil, o)
+			continue
+		case source.FormatJSONPatch:
+			var ops []operation
+			if err := j.json.Decode(m.Data, &ops); err != nil {
+				return nil, nil, err
+			}
+			if err := mg.jsonPatch(merged, ops, o); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
 		codec, ok := j.opts.Encoding[m.Format]
 		if !ok {
 			// fallback
@@ -37,16 +73,15 @@ func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, err
 
 		var data map[string]interface{}
 		if err := codec.Decode(m.Data, &data); err != nil {
-			return nil, err
-		}
-		if err := mergo.Map(&merged, data, mergo.WithOverride); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+
+		mg.merge(merged, data, nil, j.opts.Policy, o)
 	}
 
 	b, err := j.json.Encode(merged)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cs := &source.ChangeSet{
@@ -55,9 +90,9 @@ func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, err
 		Source:    "json",
 		Format:    j.json.String(),
 	}
-	cs.Checksum = cs.Sum()
+	cs.Checksum, _ = cs.Sum()
 
-	return cs, nil
+	return cs, mg.prov, nil
 }
 
 // Values 返回配置
@@ -68,7 +103,18 @@ func (j *jsonReader) Values(ch *source.ChangeSet) (reader.Values, error) {
 	if ch.Format != "json" {
 		return nil, errors.New("unsupported format")
 	}
-	return newValues(ch)
+	v, err := newValues(ch)
+	if err != nil {
+		return nil, err
+	}
+	v.strict = j.opts.Strict
+
+	if len(j.opts.Secrets) > 0 {
+		if err = v.decrypt(j.opts.Secrets, j.opts.Plaintext); err != nil {
+			return nil, fmt.Errorf("decrypt secrets failed: %w", err)
+		}
+	}
+	return v, nil
 }
 
 // String JSON
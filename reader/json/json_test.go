@@ -3,6 +3,7 @@ package json
 import (
 	"testing"
 
+	"github.com/nextpkg/nextcfg/reader"
 	"github.com/nextpkg/nextcfg/source"
 	"github.com/stretchr/testify/require"
 )
@@ -39,3 +40,39 @@ func TestReader(t *testing.T) {
 		at.Equal(test.value, v)
 	}
 }
+
+func TestMergeTrace(t *testing.T) {
+	at := require.New(t)
+
+	r := NewReader().(reader.Tracer)
+
+	file := &source.ChangeSet{
+		Source:   "file",
+		Checksum: "a",
+		Format:   "json",
+		Data:     []byte(`{"db": {"host": "localhost", "port": 3306}, "log": {"level": "info"}, "tags": ["a"]}`),
+	}
+	env := &source.ChangeSet{
+		Source:   "env",
+		Checksum: "b",
+		Format:   "json",
+		Data:     []byte(`{"db": {"host": "mysql"}, "log": "off", "tags": ["b"]}`),
+	}
+
+	c, prov, err := r.MergeTrace(file, env)
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "mysql", "port": 3306}, "log": "off", "tags": ["b"]}`, string(c.Data))
+
+	sources := make(map[string]string)
+	for k, o := range prov {
+		sources[k] = o.Source
+	}
+	at.Equal(map[string]string{
+		"db.host": "env",
+		"db.port": "file",
+		"log":     "env",
+		"tags":    "env",
+	}, sources)
+	at.Equal("a", prov["db.port"].Checksum)
+	at.Len(prov.Filter("db"), 2)
+}
@@ -0,0 +1,132 @@
+package json
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/nextpkg/nextcfg/reader"
+)
+
+// merger merges the decoded change sets by the policies and traces the origin of the keys
+type merger struct {
+	opts reader.Options
+	prov reader.Provenance
+}
+
+// merge merges src into dst, the policy of each key is the nearest configured one
+func (m *merger) merge(dst, src map[string]interface{}, path []string, policy reader.Policy, o reader.Origin) {
+	for k, sv := range src {
+		p := append(path[:len(path):len(path)], k)
+		key := strings.Join(p, ".")
+
+		pol := policy
+		if v, ok := m.opts.Policies[key]; ok {
+			pol = v
+		}
+
+		dv, exists := dst[k]
+		dm, dstIsMap := dv.(map[string]interface{})
+		sm, srcIsMap := sv.(map[string]interface{})
+
+		switch {
+		case pol == reader.KeepFirst && exists:
+			continue
+		case pol == reader.Append && exists:
+			dl, dstIsList := dv.([]interface{})
+			sl, srcIsList := sv.([]interface{})
+			if dstIsList && srcIsList {
+				dst[k] = append(append(make([]interface{}, 0, len(dl)+len(sl)), dl...), sl...)
+				m.prov[key] = o
+				continue
+			}
+		}
+
+		if pol != reader.Replace && dstIsMap && srcIsMap {
+			m.merge(dm, sm, p, pol, o)
+			continue
+		}
+
+		dst[k] = sv
+		m.prov.Drop(key)
+		m.record(p, sv, o)
+	}
+}
+
+// record sets the origin of every leaf of v
+func (m *merger) record(path []string, v interface{}, o reader.Origin) {
+	if vm, ok := v.(map[string]interface{}); ok && len(vm) > 0 {
+		for k, sv := range vm {
+			m.record(append(path[:len(path):len(path)], k), sv, o)
+		}
+		return
+	}
+
+	m.prov[strings.Join(path, ".")] = o
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396), null deletes the key
+func (m *merger) mergePatch(dst, patch map[string]interface{}, path []string, o reader.Origin) {
+	for k, pv := range patch {
+		p := append(path[:len(path):len(path)], k)
+		key := strings.Join(p, ".")
+
+		if pv == nil {
+			delete(dst, k)
+			m.prov.Drop(key)
+			continue
+		}
+
+		pm, isMap := pv.(map[string]interface{})
+		if !isMap {
+			dst[k] = pv
+			m.prov.Drop(key)
+			m.record(p, pv, o)
+			continue
+		}
+
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm = make(map[string]interface{})
+			dst[k] = dm
+			m.prov.Drop(key)
+		}
+		m.mergePatch(dm, pm, p, o)
+
+		if len(dm) == 0 {
+			m.prov[key] = o
+		}
+	}
+}
+
+// traced returns the key of the provenance which covers the path, arrays are traced as a whole
+func traced(root interface{}, tokens []string) string {
+	cur := root
+	for i, t := range tokens {
+		cm, ok := cur.(map[string]interface{})
+		if !ok {
+			return strings.Join(tokens[:i], ".")
+		}
+		cur = cm[t]
+	}
+	return strings.Join(tokens, ".")
+}
+
+// equal compares two values as JSON, the numbers decoded from yaml or toml are ints and those of a patch floats
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize round-trips the value through JSON
+func normalize(v interface{}) interface{} {
+	b, err := sonic.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var n interface{}
+	if err = sonic.Unmarshal(b, &n); err != nil {
+		return v
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePolicy(t *testing.T) {
+	at := require.New(t)
+
+	base := &source.ChangeSet{
+		Source: "base",
+		Format: "json",
+		Data: []byte(`{
+			"plugins": ["a"],
+			"tls": {"cert": "a.pem", "key": "a.key"},
+			"features": {"x": true, "list": [1]},
+			"name": "base"
+		}`),
+	}
+	overlay := &source.ChangeSet{
+		Source: "overlay",
+		Format: "json",
+		Data: []byte(`{
+			"plugins": ["b"],
+			"tls": {"cert": "b.pem"},
+			"features": {"y": true, "list": [2]},
+			"name": "overlay"
+		}`),
+	}
+
+	testData := []struct {
+		opts   []reader.Option
+		merged string
+	}{
+		{
+			nil,
+			`{"plugins": ["b"], "tls": {"cert": "b.pem", "key": "a.key"}, "features": {"x": true, "y": true, "list": [2]}, "name": "overlay"}`,
+		},
+		{
+			[]reader.Option{
+				reader.WithPolicy(reader.Append, "plugins"),
+				reader.WithPolicy(reader.Replace, "tls"),
+				reader.WithPolicy(reader.KeepFirst, "name"),
+			},
+			`{"plugins": ["a", "b"], "tls": {"cert": "b.pem"}, "features": {"x": true, "y": true, "list": [2]}, "name": "base"}`,
+		},
+		{
+			[]reader.Option{
+				reader.WithPolicy(reader.Replace),
+				reader.WithPolicy(reader.Append, "features"),
+			},
+			`{"plugins": ["b"], "tls": {"cert": "b.pem"}, "features": {"x": true, "y": true, "list": [1, 2]}, "name": "overlay"}`,
+		},
+	}
+
+	for _, test := range testData {
+		c, err := NewReader(test.opts...).Merge(base, overlay)
+		at.Nil(err)
+		at.JSONEq(test.merged, string(c.Data))
+	}
+
+	p, err := reader.ParsePolicy("keep-first")
+	at.Nil(err)
+	at.Equal(reader.KeepFirst, p)
+	_, err = reader.ParsePolicy("unknown")
+	at.NotNil(err)
+}
+
+func TestMergePatch(t *testing.T) {
+	at := require.New(t)
+
+	r := NewReader().(reader.Tracer)
+
+	base := &source.ChangeSet{
+		Source: "base",
+		Format: "json",
+		Data:   []byte(`{"db": {"host": "localhost", "port": 3306}, "debug": true, "plugins": ["a"]}`),
+	}
+
+	c, prov, err := r.MergeTrace(base, &source.ChangeSet{
+		Source: "patch",
+		Format: source.FormatMergePatch,
+		Data:   []byte(`{"db": {"port": null, "user": "root"}, "debug": null}`),
+	})
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "localhost", "user": "root"}, "plugins": ["a"]}`, string(c.Data))
+	at.Equal("patch", prov["db.user"].Source)
+	at.NotContains(prov, "db.port")
+	at.NotContains(prov, "debug")
+
+	c, prov, err = r.MergeTrace(base, &source.ChangeSet{
+		Source: "patch",
+		Format: source.FormatJSONPatch,
+		Data: []byte(`[
+			{"op": "test", "path": "/db/port", "value": 3306},
+			{"op": "add", "path": "/plugins/-", "value": "b"},
+			{"op": "remove", "path": "/debug"},
+			{"op": "replace", "path": "/db/host", "value": "mysql"},
+			{"op": "move", "from": "/db/port", "path": "/port"},
+			{"op": "copy", "from": "/db", "path": "/backup"}
+		]`),
+	})
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "mysql"}, "plugins": ["a", "b"], "port": 3306, "backup": {"host": "mysql"}}`, string(c.Data))
+	at.Equal("patch", prov["plugins"].Source)
+	at.Equal("patch", prov["db.host"].Source)
+	at.Equal("patch", prov["port"].Source)
+	at.Equal("patch", prov["backup.host"].Source)
+	at.NotContains(prov, "debug")
+
+	_, _, err = r.MergeTrace(base, &source.ChangeSet{
+		Format: source.FormatJSONPatch,
+		Data:   []byte(`[{"op": "test", "path": "/db/port", "value": 1}]`),
+	})
+	at.NotNil(err)
+
+	// the numbers of a yaml base are ints, they equal the floats of the patch
+	c, _, err = r.MergeTrace(&source.ChangeSet{
+		Source: "base",
+		Format: "yaml",
+		Data:   []byte("db:\n  port: 8080\n  hosts: [a, b]\n"),
+	}, &source.ChangeSet{
+		Source: "patch",
+		Format: source.FormatJSONPatch,
+		Data: []byte(`[
+			{"op": "test", "path": "/db/port", "value": 8080},
+			{"op": "test", "path": "/db", "value": {"port": 8080, "hosts": ["a", "b"]}},
+			{"op": "replace", "path": "/db/port", "value": 8081}
+		]`),
+	})
+	at.Nil(err)
+	at.JSONEq(`{"db": {"port": 8081, "hosts": ["a", "b"]}}`, string(c.Data))
+}
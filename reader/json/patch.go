@@ -0,0 +1,247 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mohae/deepcopy"
+	"github.com/nextpkg/nextcfg/reader"
+)
+
+// operation of a JSON patch (RFC 6902)
+type operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+// jsonPatch applies the operations in order, it stops at the first failed one
+func (m *merger) jsonPatch(root map[string]interface{}, ops []operation, o reader.Origin) error {
+	for i, op := range ops {
+		if err := m.apply(root, op, o); err != nil {
+			return fmt.Errorf("json patch operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func (m *merger) apply(root map[string]interface{}, op operation, o reader.Origin) error {
+	tokens, err := pointer(op.Path)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 && op.Op != "test" {
+		return fmt.Errorf("the root can not be patched")
+	}
+
+	switch op.Op {
+	case "add":
+		return m.set(root, tokens, op.Value, o, false)
+	case "replace":
+		return m.set(root, tokens, op.Value, o, true)
+	case "remove":
+		return m.remove(root, tokens, o)
+	case "move", "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return err
+		}
+		v, err := get(root, from)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if err = m.remove(root, from, o); err != nil {
+				return err
+			}
+		} else {
+			v = deepcopy.Copy(v)
+		}
+		return m.set(root, tokens, v, o, false)
+	case "test":
+		v, err := get(root, tokens)
+		if err != nil {
+			return err
+		}
+		if !equal(v, op.Value) {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown operation")
+}
+
+// set adds or replaces the value at the path and traces it
+func (m *merger) set(root map[string]interface{}, tokens []string, v interface{}, o reader.Origin, replace bool) error {
+	if replace {
+		if _, err := remove(root, tokens); err != nil {
+			return err
+		}
+	}
+
+	if _, err := add(root, tokens, v); err != nil {
+		return err
+	}
+
+	key := traced(root, tokens)
+	m.prov.Drop(key)
+	if key == strings.Join(tokens, ".") {
+		m.record(tokens, v, o)
+	} else {
+		m.prov[key] = o
+	}
+	return nil
+}
+
+// remove removes the value at the path and traces it
+func (m *merger) remove(root map[string]interface{}, tokens []string, o reader.Origin) error {
+	key := traced(root, tokens)
+
+	if _, err := remove(root, tokens); err != nil {
+		return err
+	}
+
+	if key == strings.Join(tokens, ".") {
+		m.prov.Drop(key)
+	} else {
+		m.prov[key] = o
+	}
+	return nil
+}
+
+// pointer splits a JSON pointer (RFC 6901) into the unescaped tokens
+func pointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", p)
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// index parses an array index, "-" is the end of the array if allowed
+func index(t string, n int, end bool) (int, error) {
+	if t == "-" && end {
+		return n, nil
+	}
+
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || i > n || (i == n && !end) {
+		return 0, fmt.Errorf("invalid index %q", t)
+	}
+	return i, nil
+}
+
+func get(node interface{}, tokens []string) (interface{}, error) {
+	for _, t := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[t]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", t)
+			}
+			node = v
+		case []interface{}:
+			i, err := index(t, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%q not found", t)
+		}
+	}
+	return node, nil
+}
+
+// add returns the node with v added at the path, arrays are reallocated
+func add(node interface{}, tokens []string, v interface{}) (interface{}, error) {
+	t := tokens[0]
+	last := len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if last {
+			n[t] = v
+			return n, nil
+		}
+		child, ok := n[t]
+		if !ok {
+			return nil, fmt.Errorf("%q not found", t)
+		}
+		c, err := add(child, tokens[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		n[t] = c
+		return n, nil
+	case []interface{}:
+		i, err := index(t, len(n), last)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			res := make([]interface{}, 0, len(n)+1)
+			res = append(append(append(res, n[:i]...), v), n[i:]...)
+			return res, nil
+		}
+		c, err := add(n[i], tokens[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = c
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("%q not found", t)
+}
+
+// remove returns the node without the value at the path, arrays are reallocated
+func remove(node interface{}, tokens []string) (interface{}, error) {
+	t := tokens[0]
+	last := len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[t]
+		if !ok {
+			return nil, fmt.Errorf("%q not found", t)
+		}
+		if last {
+			delete(n, t)
+			return n, nil
+		}
+		c, err := remove(child, tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		n[t] = c
+		return n, nil
+	case []interface{}:
+		i, err := index(t, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			res := make([]interface{}, 0, len(n)-1)
+			return append(append(res, n[:i]...), n[i+1:]...), nil
+		}
+		c, err := remove(n[i], tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		n[i] = c
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("%q not found", t)
+}
@@ -8,31 +8,130 @@ import (
 
 	simple "github.com/bitly/go-simplejson"
 	"github.com/bytedance/sonic"
+	"github.com/mohae/deepcopy"
 	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/secrets"
 	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
 )
 
 type jsonValues struct {
-	ch *source.ChangeSet
-	sj *simple.Json
+	ch     *source.ChangeSet
+	sj     *simple.Json
+	strict bool
+	// the tree with the secrets still encrypted, nil if there are none
+	enc *simple.Json
+	// the paths of the decrypted values
+	decrypted [][]string
+	// where the keys come from
+	prov reader.Provenance
 }
 
 type jsonValue struct {
 	*simple.Json
+	// where the value comes from, for the strict errors
+	source string
+	prov   reader.Provenance
+	path   []string
+	strict bool
+	// the node with the secrets still encrypted
+	enc *simple.Json
 }
 
-func newValues(ch *source.ChangeSet) (reader.Values, error) {
+func newValues(ch *source.ChangeSet) (*jsonValues, error) {
 	sj := simple.New()
 	data, _ := reader.ReplaceEnvVars(ch.Data)
 	if err := sj.UnmarshalJSON(data); err != nil {
 		sj.SetPath(nil, string(ch.Data))
 	}
-	return &jsonValues{ch, sj}, nil
+	return &jsonValues{ch: ch, sj: sj}, nil
 }
 
 // Get 获取Json节点
 func (j *jsonValues) Get(path ...string) reader.Value {
-	return &jsonValue{j.sj.GetPath(path...)}
+	v := &jsonValue{
+		Json:   j.sj.GetPath(path...),
+		source: j.ch.Source,
+		prov:   j.prov,
+		path:   path,
+		strict: j.strict,
+	}
+	if j.enc != nil {
+		v.enc = j.enc.GetPath(path...)
+	}
+	return v
+}
+
+// decrypt replaces the inline encrypted values with their plaintext, the encrypted tree is kept for Bytes
+func (j *jsonValues) decrypt(decrypters map[string]func([]byte) ([]byte, error), plaintext bool) error {
+	orig := deepcopy.Copy(j.sj.Interface())
+
+	err := decrypt(j.sj.Interface(), nil, decrypters, &j.decrypted)
+	if err != nil || len(j.decrypted) == 0 || plaintext {
+		return err
+	}
+
+	j.enc = simple.New()
+	j.enc.SetPath(nil, orig)
+	return nil
+}
+
+// decrypt walks the tree and decrypts the inline values in place, their paths are appended to found
+func decrypt(node interface{}, path []string, decrypters map[string]func([]byte) ([]byte, error), found *[][]string) error {
+	replace := func(v interface{}, key string, set func(string)) error {
+		p := append(path[:len(path):len(path)], key)
+
+		s, ok := v.(string)
+		if !ok || !secrets.IsInline(s) {
+			return decrypt(v, p, decrypters, found)
+		}
+
+		scheme, ciphertext, err := secrets.ParseInline(s)
+		if err != nil {
+			return errors.Wrapf(err, "%s", strings.Join(p, "."))
+		}
+		fn, ok := decrypters[scheme]
+		if !ok {
+			return errors.Errorf("%s: unknown secrets scheme %q", strings.Join(p, "."), scheme)
+		}
+		b, err := fn(ciphertext)
+		if err != nil {
+			return errors.Wrapf(err, "%s: decrypt failed", strings.Join(p, "."))
+		}
+
+		set(string(b))
+		*found = append(*found, p)
+		return nil
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			k := k
+			if err := replace(v, k, func(s string) { n[k] = s }); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			i := i
+			if err := replace(v, strconv.Itoa(i), func(s string) { n[i] = s }); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Trace sets where the keys come from
+func (j *jsonValues) Trace(prov reader.Provenance) {
+	j.prov = prov
+}
+
+// Decrypted returns the paths of the decrypted inline values
+func (j *jsonValues) Decrypted() [][]string {
+	return j.decrypted
 }
 
 // Del 删除Json节点
@@ -40,27 +139,47 @@ func (j *jsonValues) Del(path ...string) {
 	// delete the tree?
 	if len(path) == 0 {
 		j.sj = simple.New()
+		j.enc = nil
+		j.decrypted = nil
 		return
 	}
 
+	del(j.sj, path)
+	if j.enc != nil {
+		del(j.enc, path)
+	}
+}
+
+func del(sj *simple.Json, path []string) {
 	if len(path) == 1 {
-		j.sj.Del(path[0])
+		sj.Del(path[0])
 		return
 	}
 
-	val := j.sj.GetPath(path[:len(path)-1]...)
+	val := sj.GetPath(path[:len(path)-1]...)
 	val.Del(path[len(path)-1])
-	j.sj.SetPath(path[:len(path)-1], val.Interface())
-	return
+	sj.SetPath(path[:len(path)-1], val.Interface())
 }
 
 // Set 设置Json节点
 func (j *jsonValues) Set(val interface{}, path ...string) {
 	j.sj.SetPath(path, val)
+	if j.enc != nil {
+		j.enc.SetPath(path, val)
+	}
 }
 
-// Bytes To Bytes
+// Bytes To Bytes, the decrypted secrets are encrypted again
 func (j *jsonValues) Bytes() []byte {
+	if j.enc != nil {
+		b, _ := j.enc.MarshalJSON()
+		return b
+	}
+	return j.Reveal()
+}
+
+// Reveal To Bytes with the secrets in plaintext
+func (j *jsonValues) Reveal() []byte {
 	b, _ := j.sj.MarshalJSON()
 	return b
 }
@@ -73,6 +192,23 @@ func (j *jsonValues) Map() map[string]interface{} {
 
 // Scan To Anything
 func (j *jsonValues) Scan(v interface{}) error {
+	if j.strict {
+		return j.ScanStrict(v)
+	}
+
+	b, err := j.sj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return sonic.Unmarshal(b, v)
+}
+
+// ScanStrict 严格模式扫描，报告未知的key和类型不匹配
+func (j *jsonValues) ScanStrict(v interface{}) error {
+	if err := reader.StrictTrace(j.prov, j.ch.Source, nil, j.sj.Interface(), v); err != nil {
+		return err
+	}
+
 	b, err := j.sj.MarshalJSON()
 	if err != nil {
 		return err
@@ -196,6 +332,23 @@ func (j *jsonValue) StringMap(def map[string]string) map[string]string {
 
 // Scan To Anything
 func (j *jsonValue) Scan(v interface{}) error {
+	if j.strict {
+		return j.ScanStrict(v)
+	}
+
+	b, err := j.Json.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return sonic.Unmarshal(b, v)
+}
+
+// ScanStrict 严格模式扫描，报告未知的key和类型不匹配
+func (j *jsonValue) ScanStrict(v interface{}) error {
+	if err := reader.StrictTrace(j.prov, j.source, j.path, j.Json.Interface(), v); err != nil {
+		return err
+	}
+
 	b, err := j.Json.MarshalJSON()
 	if err != nil {
 		return err
@@ -203,12 +356,24 @@ func (j *jsonValue) Scan(v interface{}) error {
 	return sonic.Unmarshal(b, v)
 }
 
-// Bytes To Bytes
+// Bytes To Bytes, the decrypted secrets are encrypted again
 func (j *jsonValue) Bytes() []byte {
-	b, err := j.Json.Bytes()
+	if j.enc != nil {
+		return nodeBytes(j.enc)
+	}
+	return nodeBytes(j.Json)
+}
+
+// Reveal To Bytes with the secrets in plaintext
+func (j *jsonValue) Reveal() []byte {
+	return nodeBytes(j.Json)
+}
+
+func nodeBytes(sj *simple.Json) []byte {
+	b, err := sj.Bytes()
 	if err != nil {
 		// try return marshalled
-		b, err = j.Json.MarshalJSON()
+		b, err = sj.MarshalJSON()
 		if err != nil {
 			return []byte{}
 		}
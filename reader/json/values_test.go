@@ -3,6 +3,9 @@ package json
 import (
 	"testing"
 
+	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/secrets"
+	"github.com/nextpkg/nextcfg/secrets/secretbox"
 	"github.com/nextpkg/nextcfg/source"
 	"github.com/stretchr/testify/require"
 )
@@ -74,3 +77,112 @@ func TestStructArray(t *testing.T) {
 		at.EqualValues(test.value, test.accept)
 	}
 }
+
+func TestScanStrict(t *testing.T) {
+
+	at := require.New(t)
+
+	type server struct {
+		Host string `json:"host"`
+		Port uint16 `json:"port"`
+	}
+
+	type tt struct {
+		Server server            `json:"server"`
+		Tags   []string          `json:"tags"`
+		Labels map[string]string `json:"labels"`
+	}
+
+	r := NewReader(reader.WithStrict())
+	values, err := r.Values(&source.ChangeSet{
+		Source: "file",
+		Format: "json",
+		Data:   []byte(`{"server": {"hots": "a", "port": 70000}, "tags": ["a", 1], "labels": {"k": true}}`),
+	})
+	at.Nil(err)
+
+	var v tt
+	err = values.Scan(&v)
+	at.NotNil(err)
+
+	errs, ok := err.(reader.StrictErrors)
+	at.True(ok)
+	at.Equal([]string{
+		"file: labels.k: cannot convert boolean to string",
+		"file: server.hots: unknown key",
+		"file: server.port: 70000 overflows uint16",
+		"file: tags.1: cannot convert number to string",
+	}, func() []string {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return msgs
+	}())
+
+	var s server
+	err = values.Get("server").Scan(&s)
+	at.NotNil(err)
+	at.Contains(err.Error(), "file: server.hots: unknown key")
+
+	// not strict by default
+	values, err = NewReader().Values(&source.ChangeSet{Format: "json", Data: []byte(`{"tags": [], "foo": 1}`)})
+	at.Nil(err)
+	at.Nil(values.Scan(&v))
+}
+
+func TestSecrets(t *testing.T) {
+	at := require.New(t)
+
+	key := make([]byte, 32)
+	sb := secretbox.NewSecrets()
+	at.Nil(sb.Init(secrets.Key(key)))
+
+	ciphertext, err := sb.Encrypt([]byte("s3cret"))
+	at.Nil(err)
+	enc := secrets.Inline("secretbox", ciphertext)
+
+	data := []byte(`{"db": {"user": "root", "password": "` + enc + `"}, "tokens": ["` + enc + `"]}`)
+	r := NewReader(reader.WithSecrets("secretbox", sb))
+
+	values, err := r.Values(&source.ChangeSet{Format: "json", Data: data})
+	at.Nil(err)
+	at.Equal("s3cret", values.Get("db", "password").String(""))
+	at.Equal([]string{"s3cret"}, values.Get("tokens").StringSlice(nil))
+
+	var v struct {
+		DB struct {
+			Password string `json:"password"`
+		} `json:"db"`
+	}
+	at.Nil(values.Scan(&v))
+	at.Equal("s3cret", v.DB.Password)
+
+	// the secrets are encrypted again unless revealed
+	at.NotContains(string(values.Bytes()), "s3cret")
+	at.Contains(string(values.Bytes()), enc)
+	at.NotContains(string(values.Get("db").Bytes()), "s3cret")
+	at.Contains(string(reader.Reveal(values)), "s3cret")
+	at.Contains(string(reader.Reveal(values.Get("db"))), "s3cret")
+
+	values.Set("changed", "db", "password")
+	at.Contains(string(values.Bytes()), "changed")
+	values.Del("db", "password")
+	at.NotContains(string(values.Bytes()), "changed")
+
+	values, err = NewReader(reader.WithSecrets("secretbox", sb), reader.WithPlaintext()).
+		Values(&source.ChangeSet{Format: "json", Data: data})
+	at.Nil(err)
+	at.Contains(string(values.Bytes()), "s3cret")
+
+	// unknown schemes and bad ciphertexts are rejected
+	_, err = NewReader(reader.WithSecrets("box", sb)).Values(&source.ChangeSet{Format: "json", Data: data})
+	at.ErrorContains(err, `db.password: unknown secrets scheme "secretbox"`)
+	_, err = r.Values(&source.ChangeSet{Format: "json", Data: []byte(`{"password": "ENC[secretbox,AAAA]"}`)})
+	at.ErrorContains(err, "password: decrypt failed")
+
+	// left alone without secrets
+	values, err = NewReader().Values(&source.ChangeSet{Format: "json", Data: data})
+	at.Nil(err)
+	at.Equal(enc, values.Get("db", "password").String(""))
+}
@@ -7,11 +7,22 @@ import (
 	"github.com/nextpkg/nextcfg/encoder/toml"
 	"github.com/nextpkg/nextcfg/encoder/xml"
 	"github.com/nextpkg/nextcfg/encoder/yaml"
+	"github.com/nextpkg/nextcfg/secrets"
 )
 
 // Options 选项
 type Options struct {
 	Encoding map[string]encoder.Encoder
+	// Strict makes Scan fail on unknown keys and type mismatches
+	Strict bool
+	// Policy is the default merge policy
+	Policy Policy
+	// Policies of the dot separated key paths, inherited by the sub paths
+	Policies map[string]Policy
+	// Secrets decrypt the inline encrypted values by scheme
+	Secrets map[string]func(ciphertext []byte) ([]byte, error)
+	// Plaintext keeps the decrypted secrets in Bytes, they are encrypted again by default
+	Plaintext bool
 }
 
 // Option 选项
@@ -44,3 +55,30 @@ func WithEncoder(e encoder.Encoder) Option {
 		o.Encoding[e.String()] = e
 	}
 }
+
+// WithStrict 严格模式，Scan时未知的key和类型不匹配都会报错
+func WithStrict() Option {
+	return func(o *Options) {
+		o.Strict = true
+	}
+}
+
+// WithSecrets decrypts the inline values ENC[scheme,base64] of every source with s.
+// opts are passed to Decrypt, e.g. secrets.SenderPublicKey for box.
+func WithSecrets(scheme string, s secrets.Secrets, opts ...secrets.DecryptOption) Option {
+	return func(o *Options) {
+		if o.Secrets == nil {
+			o.Secrets = make(map[string]func([]byte) ([]byte, error))
+		}
+		o.Secrets[scheme] = func(ciphertext []byte) ([]byte, error) {
+			return s.Decrypt(ciphertext, opts...)
+		}
+	}
+}
+
+// WithPlaintext keeps the decrypted secrets in Values.Bytes, e.g. for debugging
+func WithPlaintext() Option {
+	return func(o *Options) {
+		o.Plaintext = true
+	}
+}
@@ -0,0 +1,61 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides how a value is merged into the value of the earlier change sets
+type Policy int
+
+const (
+	// Deep merges maps recursively, any other value replaces the earlier one
+	Deep Policy = iota
+	// Replace replaces the earlier value, maps included
+	Replace
+	// Append appends arrays to the earlier ones, maps are merged like Deep
+	Append
+	// KeepFirst keeps the earlier value if there is one
+	KeepFirst
+)
+
+// String ...
+func (p Policy) String() string {
+	switch p {
+	case Deep:
+		return "deep"
+	case Replace:
+		return "replace"
+	case Append:
+		return "append"
+	case KeepFirst:
+		return "keep-first"
+	}
+	return "unknown"
+}
+
+// ParsePolicy parses the name of a policy, e.g. "append"
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{Deep, Replace, Append, KeepFirst} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	return Deep, fmt.Errorf("unknown merge policy %q", s)
+}
+
+// WithPolicy 合并策略，不指定路径时为全局策略，否则作用于路径（以.分隔）及其子路径
+func WithPolicy(p Policy, paths ...string) Option {
+	return func(o *Options) {
+		if len(paths) == 0 {
+			o.Policy = p
+			return
+		}
+		if o.Policies == nil {
+			o.Policies = make(map[string]Policy)
+		}
+		for _, path := range paths {
+			o.Policies[path] = p
+		}
+	}
+}
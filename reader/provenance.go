@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// Origin is the change set which provided the effective value of a key
+type Origin struct {
+	Source    string    `json:"source"`
+	Checksum  string    `json:"checksum"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Provenance maps the dot separated paths of the merged leaves to their origin
+type Provenance map[string]Origin
+
+// Tracer is implemented by the readers which know where each merged key comes from
+type Tracer interface {
+	// MergeTrace is like Merge but also returns the provenance of the merged keys
+	MergeTrace(...*source.ChangeSet) (*source.ChangeSet, Provenance, error)
+}
+
+// Traced is implemented by the values which can tell where their keys come from, e.g. in the strict errors
+type Traced interface {
+	// Trace sets the provenance of the merged change set the values are read from
+	Trace(Provenance)
+}
+
+// Sources returns the sorted sources of the keys at or under the path
+func (p Provenance) Sources(path ...string) []string {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, o := range p.Filter(path...) {
+		if !seen[o.Source] {
+			seen[o.Source] = true
+			sources = append(sources, o.Source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Filter returns a copy of the origins of the keys at or under the path
+func (p Provenance) Filter(path ...string) Provenance {
+	key := strings.Join(path, ".")
+	res := make(Provenance)
+	for k, o := range p {
+		if len(path) == 0 || k == key || strings.HasPrefix(k, key+".") {
+			res[k] = o
+		}
+	}
+	return res
+}
+
+// Drop removes the origins of the keys at or under the path
+func (p Provenance) Drop(key string) {
+	for k := range p {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(p, k)
+		}
+	}
+}
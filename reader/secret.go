@@ -0,0 +1,21 @@
+package reader
+
+// Revealer is implemented by the values which encrypt the decrypted secrets again in Bytes
+type Revealer interface {
+	// Reveal is like Bytes but with the secrets in plaintext
+	Reveal() []byte
+}
+
+// Reveal returns the bytes of b with the secrets in plaintext, e.g. for validation
+func Reveal(b interface{ Bytes() []byte }) []byte {
+	if r, ok := b.(Revealer); ok {
+		return r.Reveal()
+	}
+	return b.Bytes()
+}
+
+// Decrypted is implemented by the values which decrypted inline secrets
+type Decrypted interface {
+	// Decrypted returns the key paths of the decrypted values
+	Decrypted() [][]string
+}
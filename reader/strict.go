@@ -0,0 +1,266 @@
+package reader
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StrictScanner is implemented by the values which can scan strictly
+type StrictScanner interface {
+	// ScanStrict is like Scan but fails on unknown keys and type mismatches
+	ScanStrict(v interface{}) error
+}
+
+// StrictError is a key which is unknown to the struct or can not be converted
+type StrictError struct {
+	// Source the key is loaded from
+	Source string
+	// Path of the key
+	Path []string
+	// Message describes the problem
+	Message string
+}
+
+// Error ...
+func (e StrictError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Source, strings.Join(e.Path, "."), e.Message)
+}
+
+// StrictErrors aggregates every StrictError found by a scan
+type StrictErrors []StrictError
+
+// Error ...
+func (e StrictErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ScanStrict scans strictly if s supports it
+func ScanStrict(s interface{ Scan(v interface{}) error }, v interface{}) error {
+	if ss, ok := s.(StrictScanner); ok {
+		return ss.ScanStrict(v)
+	}
+	return s.Scan(v)
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textType        = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Strict checks that every key of the decoded json tree maps to a field of v
+// and every value can be converted to the field type, keys are matched like encoding/json does
+func Strict(source string, path []string, tree interface{}, v interface{}) error {
+	return StrictTrace(nil, source, path, tree, v)
+}
+
+// StrictTrace is like Strict but reports the source each key comes from by the provenance,
+// source is reported for the keys it doesn't know
+func StrictTrace(prov Provenance, source string, path []string, tree interface{}, v interface{}) error {
+	origin := func(p []string) string {
+		if sources := prov.Sources(p...); len(sources) > 0 {
+			return strings.Join(sources, ",")
+		}
+		return source
+	}
+
+	var errs StrictErrors
+	check(origin, path, tree, reflect.TypeOf(v), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func check(origin func([]string) string, path []string, tree interface{}, t reflect.Type, errs *StrictErrors) {
+	if t == nil || tree == nil {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// custom decoding, nothing to check
+	if reflect.PtrTo(t).Implements(unmarshalerType) || reflect.PtrTo(t).Implements(textType) {
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, StrictError{
+			Source:  origin(path),
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	mismatch := func() {
+		fail("cannot convert %s to %s", jsonType(tree), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+	case reflect.Struct:
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		collectFields(t, fields)
+		for _, k := range sortedKeys(m) {
+			p := append(path[:len(path):len(path)], k)
+			ft, ok := fields[k]
+			if !ok {
+				for name, typ := range fields {
+					if strings.EqualFold(name, k) {
+						ft, ok = typ, true
+						break
+					}
+				}
+			}
+			if !ok {
+				*errs = append(*errs, StrictError{Source: origin(p), Path: p, Message: "unknown key"})
+				continue
+			}
+			check(origin, p, m[k], ft, errs)
+		}
+	case reflect.Map:
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			check(origin, append(path[:len(path):len(path)], k), m[k], t.Elem(), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := tree.(string); ok {
+				return
+			}
+		}
+		list, ok := tree.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for i, item := range list {
+			check(origin, append(path[:len(path):len(path)], strconv.Itoa(i)), item, t.Elem(), errs)
+		}
+	case reflect.String:
+		if _, ok := tree.(string); !ok {
+			mismatch()
+		}
+	case reflect.Bool:
+		if _, ok := tree.(bool); !ok {
+			mismatch()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := number(tree)
+		if !ok {
+			mismatch()
+			return
+		}
+		bits := t.Bits()
+		if f != math.Trunc(f) || f < -math.Pow(2, float64(bits-1)) || f >= math.Pow(2, float64(bits-1)) {
+			fail("%v overflows %s", tree, t)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := number(tree)
+		if !ok {
+			mismatch()
+			return
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.Pow(2, float64(t.Bits())) {
+			fail("%v overflows %s", tree, t)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := number(tree); !ok {
+			mismatch()
+		}
+	default:
+		fail("unsupported type %s", t)
+	}
+}
+
+// collectFields maps the json keys of a struct to the field types
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// embedded structs are flattened like encoding/json does
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, fields)
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields[name] = sf.Type
+	}
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64, int, int64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -9,14 +9,23 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var cfgSource string
+var (
+	cfgSource string
+	profile   string
+)
 
 // CfgFlag 数据源的命令行标志
 const CfgFlag = "cfg"
 
+// ProfileFlag 配置profile的命令行标志，例如 --profile=staging
+const ProfileFlag = "profile"
+
 func init() {
 	// --cfg
 	cmd.Root().PersistentFlags().StringVar(&cfgSource, CfgFlag, "file", "")
+	// --profile
+	cmd.Root().PersistentFlags().StringVar(&profile, ProfileFlag, "",
+		"load config.<profile>.yaml over config.yaml, then config.local.yaml")
 
 	// enrich usage
 	next := cmd.Root().HelpFunc()
@@ -27,10 +36,17 @@ func init() {
 	})
 }
 
+// GetCfgSource 获取--cfg指定的数据源
 func GetCfgSource() string {
 	return cfgSource
 }
 
+// GetProfile 获取--profile指定的profile，未指定时为空
+func GetProfile() string {
+	return profile
+}
+
+// SetCfgSource 设置--cfg的默认数据源
 func SetCfgSource(sourceName string) {
 	flag := cmd.Root().Flag(CfgFlag)
 	if flag == nil {
@@ -39,7 +55,7 @@ func SetCfgSource(sourceName string) {
 	}
 
 	flag.DefValue = sourceName
-	err := flag.Value.Set(CfgFlag)
+	err := flag.Value.Set(sourceName)
 	if err != nil {
 		slog.Error("reset --cfg value failed. ", slog.Any("err", err))
 		os.Exit(1)
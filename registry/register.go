@@ -22,7 +22,7 @@ func SetCfgLoader(sourceName string, sourceLoad Registry) {
 	cfgRegistry[sourceName] = sourceLoad
 }
 
-// GetCfgLoader 获取配置加载器
+// GetCfgLoader 获取配置加载器，由命令行--cfg决定，因此作为进程默认配置
 // sourceName 数据源名称，例如 file/url...
 // loaderPara 加载器所使用的参数
 func GetCfgLoader(sourceName, loaderPara string) *nextcfg.Loaders {
@@ -34,7 +34,7 @@ func GetCfgLoader(sourceName, loaderPara string) *nextcfg.Loaders {
 		return nil
 	}
 
-	return nextcfg.InitLoader(cc(loaderPara))
+	return nextcfg.InitLoader(cc(loaderPara), nextcfg.AsDefault())
 }
 
 func GetRegistryList() []string {
@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// Generate derives a schema document from a struct, keys unknown to the struct are rejected.
+// The `default`, `required`, `min`, `max`, `oneof` and `pattern` struct tags are translated too.
+func Generate(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("struct or pointer to struct is required")
+	}
+
+	g := &generator{
+		root:      t,
+		stack:     make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+		names:     make(map[reflect.Type]string),
+		defs:      make(map[string]interface{}),
+	}
+
+	doc := g.typeSchema(t)
+	doc["$schema"] = draft
+	if len(g.defs) > 0 {
+		doc["$defs"] = g.defs
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// FromStruct generates and compiles the schema of a struct
+func FromStruct(v interface{}) (*Schema, error) {
+	data, err := Generate(v)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(data)
+}
+
+// generator tracks the struct types on the way down, so a recursive type is generated once
+// into $defs and referenced, instead of being expanded forever
+type generator struct {
+	root      reflect.Type
+	stack     map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+	names     map[reflect.Type]string
+	defs      map[string]interface{}
+}
+
+// ref references a recursive type, the root is the document itself
+func (g *generator) ref(t reflect.Type) map[string]interface{} {
+	if t == g.root {
+		return map[string]interface{}{"$ref": "#"}
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		for i := 2; g.taken(name); i++ {
+			name = t.Name() + strconv.Itoa(i)
+		}
+		g.names[t] = name
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+// taken reports whether another type is defined under the name
+func (g *generator) taken(name string) bool {
+	for _, n := range g.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case durationType:
+		// nanoseconds, the only form Scan accepts
+		return map[string]interface{}{"type": "integer"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case bytesType:
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if g.stack[t] {
+			g.recursive[t] = true
+			return g.ref(t)
+		}
+		if _, ok := g.defs[g.names[t]]; ok && g.recursive[t] {
+			return g.ref(t)
+		}
+
+		g.stack[t] = true
+		props := make(map[string]interface{})
+		var required []string
+		g.structSchema(t, props, &required)
+		delete(g.stack, t)
+
+		s := map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+
+		if g.recursive[t] && t != g.root {
+			ref := g.ref(t)
+			g.defs[g.names[t]] = s
+			return ref
+		}
+		return s
+	}
+
+	// interface{} accepts anything
+	return map[string]interface{}{}
+}
+
+func (g *generator) structSchema(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// embedded structs are flattened like encoding/json does
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.structSchema(ft, props, required)
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		s := g.typeSchema(sf.Type)
+		rules(sf, s)
+		props[name] = s
+
+		_, hasDefault := sf.Tag.Lookup("default")
+		if sf.Tag.Get("required") == "true" && !hasDefault {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// rules translates the struct tags into schema keywords
+func rules(sf reflect.StructField, s map[string]interface{}) {
+	ft := sf.Type
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+
+	var minKey, maxKey string
+	switch s["type"] {
+	case "integer", "number":
+		minKey, maxKey = "minimum", "maximum"
+	case "string":
+		minKey, maxKey = "minLength", "maxLength"
+	case "array":
+		minKey, maxKey = "minItems", "maxItems"
+	case "object":
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+
+	if min, ok := sf.Tag.Lookup("min"); ok && minKey != "" {
+		if n, ok := number(ft, min); ok {
+			s[minKey] = n
+		}
+	}
+	if max, ok := sf.Tag.Lookup("max"); ok && maxKey != "" {
+		if n, ok := number(ft, max); ok {
+			s[maxKey] = n
+		}
+	}
+
+	if oneof, ok := sf.Tag.Lookup("oneof"); ok {
+		var enum []interface{}
+		for _, o := range strings.Fields(oneof) {
+			if v, ok := scalar(ft, o); ok {
+				enum = append(enum, v)
+			}
+		}
+		s["enum"] = enum
+	}
+
+	if pattern, ok := sf.Tag.Lookup("pattern"); ok && s["type"] == "string" {
+		s["pattern"] = pattern
+	}
+
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		if s["type"] == "array" {
+			var items []interface{}
+			for _, part := range strings.Split(def, ",") {
+				if v, ok := scalar(ft.Elem(), strings.TrimSpace(part)); ok {
+					items = append(items, v)
+				}
+			}
+			s["default"] = items
+		} else if v, ok := scalar(ft, def); ok {
+			s["default"] = v
+		}
+	}
+}
+
+// number parses a min/max limit, lengths for strings and collections
+func number(t reflect.Type, s string) (interface{}, bool) {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+	return scalar(t, s)
+}
+
+// scalar parses the string form of a tag value as a json value of t
+func scalar(t reflect.Type, s string) (interface{}, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		d, err := time.ParseDuration(s)
+		return int64(d), err == nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return s, true
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return n, err == nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	}
+	return nil, false
+}
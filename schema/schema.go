@@ -0,0 +1,120 @@
+// Package schema validates merged config snapshots against a JSON Schema (draft 2020-12)
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const url = "nextcfg.schema.json"
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	s   *jsonschema.Schema
+	raw []byte
+}
+
+// Error is a violation located by the JSON pointer of the instance
+type Error struct {
+	// Pointer of the invalid value, e.g. /server/port
+	Pointer string
+	// Message describes the violated keyword
+	Message string
+}
+
+// Error ...
+func (e Error) Error() string {
+	return e.Pointer + ": " + e.Message
+}
+
+// Errors is returned by Validate, sorted by pointer
+type Errors []Error
+
+// Error ...
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Compile compiles a JSON Schema document, draft 2020-12 is assumed if $schema is missing
+func Compile(data []byte) (*Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+
+	if err := c.AddResource(url, bytes.NewReader(data)); err != nil {
+		return nil, errors.Wrap(err, "invalid schema")
+	}
+
+	s, err := c.Compile(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "compile schema failed")
+	}
+
+	return &Schema{s: s, raw: data}, nil
+}
+
+// MustCompile is like Compile but panics on error
+func MustCompile(data []byte) *Schema {
+	s, err := Compile(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Bytes returns the schema document
+func (s *Schema) Bytes() []byte {
+	return s.raw
+}
+
+// Validate checks the json encoded data, the violations are returned as Errors
+func (s *Schema) Validate(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return errors.Wrap(err, "decode failed")
+	}
+
+	err := s.s.Validate(v)
+	if err == nil {
+		return nil
+	}
+
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	errs := leaves(ve, nil)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Pointer < errs[j].Pointer
+	})
+
+	return errs
+}
+
+// leaves collects the innermost causes, the outer ones only say "doesn't validate"
+func leaves(ve *jsonschema.ValidationError, errs Errors) Errors {
+	if len(ve.Causes) == 0 {
+		p := ve.InstanceLocation
+		if p == "" {
+			p = "/"
+		}
+		return append(errs, Error{Pointer: p, Message: ve.Message})
+	}
+
+	for _, c := range ve.Causes {
+		errs = leaves(c, errs)
+	}
+	return errs
+}
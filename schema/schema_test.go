@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type server struct {
+	Host    string        `json:"host" default:"localhost"`
+	Port    int           `json:"port" min:"1" max:"65535"`
+	Timeout time.Duration `json:"timeout" max:"1m"`
+}
+
+type cfg struct {
+	Server server            `json:"server"`
+	Mode   string            `json:"mode" required:"true" oneof:"debug release"`
+	Name   string            `json:"name" pattern:"^[a-z]+$"`
+	Tags   []string          `json:"tags" max:"2"`
+	Extra  map[string]string `json:"extra"`
+	Any    interface{}       `json:"any"`
+}
+
+func TestGenerate(t *testing.T) {
+	at := require.New(t)
+
+	data, err := Generate(&cfg{})
+	at.Nil(err)
+
+	var doc map[string]interface{}
+	at.Nil(json.Unmarshal(data, &doc))
+	at.Equal(draft, doc["$schema"])
+	at.Equal([]interface{}{"mode"}, doc["required"])
+	at.Equal(false, doc["additionalProperties"])
+
+	props := doc["properties"].(map[string]interface{})
+	at.Equal([]interface{}{"debug", "release"}, props["mode"].(map[string]interface{})["enum"])
+
+	srv := props["server"].(map[string]interface{})["properties"].(map[string]interface{})
+	at.Equal("localhost", srv["host"].(map[string]interface{})["default"])
+	at.Equal(float64(65535), srv["port"].(map[string]interface{})["maximum"])
+	at.Equal("integer", srv["timeout"].(map[string]interface{})["type"])
+	at.Equal(float64(time.Minute), srv["timeout"].(map[string]interface{})["maximum"])
+
+	_, err = Generate(1)
+	at.NotNil(err)
+}
+
+func TestValidate(t *testing.T) {
+	at := require.New(t)
+
+	s, err := FromStruct(cfg{})
+	at.Nil(err)
+
+	at.Nil(s.Validate([]byte(`{"mode": "debug", "server": {"port": 80}, "any": [1]}`)))
+
+	err = s.Validate([]byte(`{"mode": "test", "name": "Foo", "server": {"port": 70000, "hots": "x"}, "tags": ["a", "b", "c"]}`))
+	at.NotNil(err)
+
+	errs, ok := err.(Errors)
+	at.True(ok)
+
+	pointers := make([]string, 0, len(errs))
+	for _, e := range errs {
+		pointers = append(pointers, e.Pointer)
+	}
+	at.Equal([]string{"/mode", "/name", "/server", "/server/port", "/tags"}, pointers)
+	at.Contains(err.Error(), "/server/port: must be <= 65535")
+
+	_, err = Compile([]byte(`{"type": 1}`))
+	at.NotNil(err)
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children"`
+	Parent   *node  `json:"parent"`
+}
+
+type tree struct {
+	Root  node   `json:"root"`
+	Nodes []node `json:"nodes"`
+	Self  *tree  `json:"self"`
+}
+
+func TestGenerateRecursive(t *testing.T) {
+	at := require.New(t)
+
+	data, err := Generate(tree{})
+	at.Nil(err)
+
+	var doc map[string]interface{}
+	at.Nil(json.Unmarshal(data, &doc))
+	at.Contains(doc["$defs"], "node")
+
+	props := doc["properties"].(map[string]interface{})
+	at.Equal("#/$defs/node", props["root"].(map[string]interface{})["$ref"])
+	at.Equal("#", props["self"].(map[string]interface{})["$ref"])
+
+	s, err := Compile(data)
+	at.Nil(err)
+	at.Nil(s.Validate([]byte(`{"root": {"name": "a", "children": [{"name": "b", "children": []}]}, "self": {"nodes": []}}`)))
+
+	err = s.Validate([]byte(`{"root": {"children": [{"nmae": "b"}]}}`))
+	at.NotNil(err)
+	at.Contains(err.Error(), "/root/children/0")
+}
+
+func TestValidateDuration(t *testing.T) {
+	at := require.New(t)
+
+	s, err := FromStruct(server{})
+	at.Nil(err)
+
+	// nanoseconds only, Scan can't decode the strings
+	for _, v := range []string{`0`, `5000000000`} {
+		at.Nil(s.Validate([]byte(`{"timeout": `+v+`}`)), v)
+	}
+	for _, v := range []string{`"5s"`, `"0"`, `true`, `120000000000`} {
+		at.NotNil(s.Validate([]byte(`{"timeout": `+v+`}`)), v)
+	}
+}
@@ -0,0 +1,221 @@
+package nextcfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/mohae/deepcopy"
+	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/reader"
+	nsecrets "github.com/nextpkg/nextcfg/secrets"
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// Redacted is printed instead of the secrets
+const Redacted = "******"
+
+// Secret is a string which is redacted in fmt, slog and JSON output, use Reveal to get the plaintext
+type Secret string
+
+// Reveal returns the plaintext
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String ...
+func (s Secret) String() string {
+	return Redacted
+}
+
+// Format redacts every verb, including %#v and %q
+func (s Secret) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, Redacted)
+}
+
+// LogValue implements slog.LogValuer
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// MarshalJSON ...
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// secretPaths returns the key paths of the Secret fields and of the fields tagged `secret:"true"`.
+// The elements of slices and maps are matched by "*".
+func secretPaths(v interface{}) [][]string {
+	if v == nil {
+		return nil
+	}
+
+	var paths [][]string
+	walkSecrets(reflect.TypeOf(v), nil, &paths, map[reflect.Type]bool{})
+	return paths
+}
+
+func walkSecrets(rt reflect.Type, path []string, paths *[][]string, seen map[reflect.Type]bool) {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	if rt == secretType {
+		*paths = append(*paths, path)
+		return
+	}
+
+	switch rt.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		walkSecrets(rt.Elem(), append(path[:len(path):len(path)], "*"), paths, seen)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	// recursive types
+	if seen[rt] {
+		return
+	}
+	seen[rt] = true
+	defer delete(seen, rt)
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+
+		name, ok := fieldName(sf)
+		if !ok {
+			continue
+		}
+
+		// embedded structs are flattened like encoding/json does
+		if sf.Anonymous && name == "" {
+			walkSecrets(sf.Type, path, paths, seen)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		p := append(path[:len(path):len(path)], name)
+		if sf.Tag.Get("secret") == "true" {
+			*paths = append(*paths, p)
+			continue
+		}
+		walkSecrets(sf.Type, p, paths, seen)
+	}
+}
+
+// isSecret reports whether the path is at or under one of the secret paths
+func isSecret(path []string, secrets [][]string) bool {
+	for _, s := range secrets {
+		if len(s) > len(path) {
+			continue
+		}
+
+		match := true
+		for i := range s {
+			if s[i] != "*" && !strings.EqualFold(s[i], path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces the values at the secret paths of the tree in place.
+// The inline ENC[...] values are kept if inline is set, e.g. so that a snapshot can be restored from.
+func redact(node interface{}, path []string, secrets [][]string, inline bool) interface{} {
+	if node != nil && isSecret(path, secrets) {
+		if s, ok := node.(string); ok && inline && nsecrets.IsInline(s) {
+			return node
+		}
+		return Redacted
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			n[k] = redact(v, append(path[:len(path):len(path)], k), secrets, inline)
+		}
+	case []interface{}:
+		for i, v := range n {
+			n[i] = redact(v, append(path[:len(path):len(path)], fmt.Sprint(i)), secrets, inline)
+		}
+	}
+	return node
+}
+
+// redactSets redacts the data of the change sets in place, the data with secrets is re-encoded as JSON.
+// The inline ENC[...] values are kept, the data which can not be decoded is left as it is.
+func (c *config) redactSets(sets ...*source.ChangeSet) {
+	for _, cs := range sets {
+		val, err := c.setValues(cs)
+		if err != nil {
+			continue
+		}
+
+		secrets := c.secrets(val)
+		if len(secrets) == 0 {
+			continue
+		}
+
+		var tree interface{}
+		if err = sonic.Unmarshal(val.Bytes(), &tree); err != nil {
+			continue
+		}
+
+		// the sets without any of the secrets keep their data and format
+		orig := deepcopy.Copy(tree)
+		if tree = redact(tree, nil, secrets, true); reflect.DeepEqual(orig, tree) {
+			continue
+		}
+		cs.Data, _ = sonic.Marshal(tree)
+		cs.Format = c.opts.Reader.String()
+	}
+}
+
+// setValues decodes a single change set by its own format, a merge patch is a JSON document
+func (c *config) setValues(cs *source.ChangeSet) (reader.Values, error) {
+	switch cs.Format {
+	case c.opts.Reader.String():
+	case source.FormatMergePatch:
+		cs = &source.ChangeSet{Data: cs.Data, Format: c.opts.Reader.String()}
+	default:
+		merged, err := c.opts.Reader.Merge(cs)
+		if err != nil {
+			return nil, err
+		}
+		cs = merged
+	}
+	return c.opts.Reader.Values(cs)
+}
+
+// redactSnapshot returns a copy of snap with the secrets of its change sets redacted
+func (c *config) redactSnapshot(snap *loader.Snapshot) *loader.Snapshot {
+	snap = loader.Copy(snap)
+	c.redactSets(append([]*source.ChangeSet{snap.ChangeSet}, snap.Sources...)...)
+	return snap
+}
+
+// asSecret wraps a value of a secret path, so that it's redacted when printed
+func asSecret(v interface{}) interface{} {
+	switch s := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return Secret(s)
+	}
+	return Secret(fmt.Sprint(v))
+}
@@ -0,0 +1,183 @@
+package nextcfg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/nextpkg/nextcfg/source/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	at := assert.New(t)
+
+	s := nextcfg.Secret("hunter2")
+	at.Equal("hunter2", s.Reveal())
+
+	for _, verb := range []string{"%v", "%s", "%q", "%#v", "%+v"} {
+		at.Equal(nextcfg.Redacted, fmt.Sprintf(verb, s), verb)
+	}
+
+	b, err := json.Marshal(struct{ Password nextcfg.Secret }{s})
+	at.Nil(err)
+	at.Equal(`{"Password":"******"}`, string(b))
+
+	buf := &bytes.Buffer{}
+	slog.New(slog.NewJSONHandler(buf, nil)).Info("login", slog.Any("password", s))
+	at.NotContains(buf.String(), "hunter2")
+	at.Contains(buf.String(), `"password":"******"`)
+}
+
+type secretCfg struct {
+	User     string         `json:"user"`
+	Password nextcfg.Secret `json:"password"`
+	Token    string         `json:"token" secret:"true"`
+	Backends []struct {
+		Key nextcfg.Secret `json:"key"`
+	} `json:"backends"`
+}
+
+func TestSecretLoaders(t *testing.T) {
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"user": "root", "password": "hunter2", "token": "t0k3n", "backends": [{"key": "k1"}]}`)))
+
+	tc, err := nextcfg.New(secretCfg{}, withSource(src))
+	at.Nil(err)
+	defer tc.Loaders().Close()
+
+	data := tc.Get()
+	at.Equal("hunter2", data.Password.Reveal())
+	at.Equal("t0k3n", data.Token)
+	at.Equal("k1", data.Backends[0].Key.Reveal())
+
+	b := string(tc.Loaders().GetCfg().Bytes())
+	at.Contains(b, `"user":"root"`)
+	for _, v := range []string{"hunter2", "t0k3n", "k1"} {
+		at.NotContains(b, v)
+	}
+}
+
+func TestConfigRedact(t *testing.T) {
+	at := assert.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"host": "localhost", "password": "hunter2"}}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+	defer conf.Close()
+
+	conf.Redact("db", "password")
+	at.NotContains(string(conf.Bytes()), "hunter2")
+	at.Equal("hunter2", conf.Get("db", "password").String(""))
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := conf.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"db": {"host": "localhost", "password": "s3cret"}}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.Len(ev.Changes, 1)
+		out := fmt.Sprintf("%v %+v", ev.Changes, ev.Changes[0])
+		at.False(strings.Contains(out, "hunter2") || strings.Contains(out, "s3cret"), out)
+		at.Equal("s3cret", ev.Changes[0].New.(nextcfg.Secret).Reveal())
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+}
+
+func TestConfigRedactSnapshots(t *testing.T) {
+	at := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"host": "localhost", "password": "hunter2"}}`)))
+
+	conf, err := nextcfg.NewConfig(nextcfg.WithSnapshotFile(path), nextcfg.WithSource(src))
+	at.Nil(err)
+	defer conf.Close()
+
+	// the snapshot file saved before is saved again
+	conf.Redact("db", "password")
+	b, err := os.ReadFile(path)
+	at.Nil(err)
+	at.NotContains(string(b), "hunter2")
+
+	sources := conf.Sources()
+	at.Len(sources, 1)
+	at.NotContains(string(sources[0].Data), "hunter2")
+	at.Contains(string(sources[0].Data), nextcfg.Redacted)
+
+	history := conf.History()
+	at.NotEmpty(history)
+	for _, snap := range history {
+		at.NotContains(string(snap.ChangeSet.Data), "hunter2")
+		for _, cs := range snap.Sources {
+			at.NotContains(string(cs.Data), "hunter2")
+		}
+	}
+
+	m := conf.Map()
+	at.Equal(nextcfg.Redacted, m["db"].(map[string]interface{})["password"])
+	at.Equal("hunter2", conf.Get("db", "password").String(""))
+
+	// the redacted snapshot file boots with the secret redacted
+	at.Nil(conf.Close())
+	conf, err = nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(memory.NewSource()),
+	)
+	at.Nil(err)
+	at.Equal("localhost", conf.Get("db", "host").String(""))
+	at.Equal(nextcfg.Redacted, conf.Get("db", "password").String(""))
+}
+
+func TestConfigRedactYAML(t *testing.T) {
+	at := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	conf, err := nextcfg.NewConfig(
+		nextcfg.WithSnapshotFile(path),
+		nextcfg.WithSource(memory.NewSource(source.WithName("plain"), memory.WithYAML([]byte("db:\n  host: localhost\n")))),
+		nextcfg.WithSource(memory.NewSource(source.WithName("secret"), memory.WithYAML([]byte("db:\n  password: hunter2\n")))),
+	)
+	at.Nil(err)
+	defer conf.Close()
+	conf.Redact("db", "password")
+
+	// the set without secrets is kept as it is, the one with secrets is redacted as JSON
+	check := func(sets []*source.ChangeSet) {
+		at.Len(sets, 2)
+		at.Equal("yaml", sets[0].Format)
+		at.Equal("db:\n  host: localhost\n", string(sets[0].Data))
+		at.Equal("json", sets[1].Format)
+		at.JSONEq(`{"db": {"password": "`+nextcfg.Redacted+`"}}`, string(sets[1].Data))
+	}
+
+	check(conf.Sources())
+	for _, snap := range conf.History() {
+		check(snap.Sources)
+	}
+
+	b, err := os.ReadFile(path)
+	at.Nil(err)
+	var snap loader.Snapshot
+	at.Nil(json.Unmarshal(b, &snap))
+	check(snap.Sources)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
 	naclBox "golang.org/x/crypto/nacl/box"
 
 	"github.com/nextpkg/nextcfg/secrets"
@@ -33,6 +34,16 @@ func (b *box) Init(opts ...secrets.Option) error {
 	for _, o := range opts {
 		o(&b.options)
 	}
+	if kr := b.options.Keyring; kr != nil {
+		for _, id := range kr.IDs() {
+			if key, _ := kr.Get(id); len(key) != keyLength {
+				return errors.Errorf("private key %q must be %d bytes long", id, keyLength)
+			}
+		}
+		if len(b.options.PrivateKey) == 0 && len(b.options.PublicKey) == 0 && len(kr.IDs()) > 0 {
+			return nil
+		}
+	}
 	if len(b.options.PrivateKey) != keyLength || len(b.options.PublicKey) != keyLength {
 		return errors.Errorf("a public key and a private key of length %d must both be provided", keyLength)
 	}
@@ -51,12 +62,25 @@ func (*box) String() string {
 	return "nacl-box"
 }
 
-// Encrypt encrypts a message with the sender's private key and the receipient's public key
+// Encrypt encrypts a message with the sender's private key and the receipient's public key.
+// With a keyring the primary key is the sender's, and the recipient defaults to itself.
 func (b *box) Encrypt(in []byte, opts ...secrets.EncryptOption) ([]byte, error) {
 	var options secrets.EncryptOptions
 	for _, o := range opts {
 		o(&options)
 	}
+	if kr := b.options.Keyring; kr != nil {
+		if id, key := kr.Primary(); id != "" {
+			// the key may have been added after Init
+			if len(key) != keyLength {
+				return []byte{}, errors.Errorf("private key %q must be %d bytes long", id, keyLength)
+			}
+			return b.encryptWith(id, key, in, options)
+		}
+	}
+	if len(b.options.PrivateKey) != keyLength {
+		return []byte{}, errors.New("no private key is defined")
+	}
 	if len(options.RecipientPublicKey) != keyLength {
 		return []byte{}, errors.New("recepient's public key must be provided")
 	}
@@ -69,20 +93,85 @@ func (b *box) Encrypt(in []byte, opts ...secrets.EncryptOption) ([]byte, error)
 	return naclBox.Seal(nonce[:], in, &nonce, &recipientPublicKey, &b.privateKey), nil
 }
 
+// encryptWith encrypts with a key of the keyring, the key id of the recipient is embedded
+func (b *box) encryptWith(id string, key []byte, in []byte, options secrets.EncryptOptions) ([]byte, error) {
+	var privateKey, recipientPublicKey [keyLength]byte
+	copy(privateKey[:], key)
+
+	switch {
+	case len(options.RecipientPublicKey) == keyLength:
+		copy(recipientPublicKey[:], options.RecipientPublicKey)
+		id = options.RecipientKeyID
+	case len(options.RecipientPublicKey) == 0:
+		// encrypted for itself, e.g. the values of its own config files
+		pub, err := curve25519.X25519(key, curve25519.Basepoint)
+		if err != nil {
+			return []byte{}, errors.Wrap(err, "couldn't derive the public key")
+		}
+		copy(recipientPublicKey[:], pub)
+	default:
+		return []byte{}, errors.Errorf("recepient's public key must be %d bytes long", keyLength)
+	}
+
+	var nonce [24]byte
+	if _, err := rand.Reader.Read(nonce[:]); err != nil {
+		return []byte{}, errors.Wrap(err, "couldn't obtain a random nonce from crypto/rand")
+	}
+	sealed := naclBox.Seal(nonce[:], in, &nonce, &recipientPublicKey, &privateKey)
+	if id == "" {
+		return sealed, nil
+	}
+	return secrets.Envelope(id, sealed), nil
+}
+
 // Decrypt Decrypts a message with the receiver's private key and the sender's public key
 func (b *box) Decrypt(in []byte, opts ...secrets.DecryptOption) ([]byte, error) {
 	var options secrets.DecryptOptions
 	for _, o := range opts {
 		o(&options)
 	}
+	if id, ciphertext, ok := secrets.OpenEnvelope(in); ok && b.options.Keyring != nil {
+		if key, found := b.options.Keyring.Get(id); found && len(key) == keyLength {
+			decrypted, err := decryptWith(key, ciphertext, options)
+			if err == nil || len(b.options.PrivateKey) == 0 {
+				return decrypted, err
+			}
+		} else if found && len(b.options.PrivateKey) == 0 {
+			return []byte{}, errors.Errorf("private key %q must be %d bytes long", id, keyLength)
+		} else if len(b.options.PrivateKey) == 0 {
+			return []byte{}, errors.Errorf("unknown key id %q", id)
+		}
+	}
+
+	// a message without key id may start like an envelope by chance
+	if len(b.options.PrivateKey) != keyLength {
+		return []byte{}, errors.New("no private key is defined")
+	}
 	if len(options.SenderPublicKey) != keyLength {
 		return []byte{}, errors.New("sender's public key bust be provided")
 	}
+	return decryptWith(b.privateKey[:], in, options)
+}
+
+// decryptWith decrypts with the private key, the sender defaults to the owner of the key
+func decryptWith(key []byte, in []byte, options secrets.DecryptOptions) ([]byte, error) {
+	sender := options.SenderPublicKey
+	if len(sender) == 0 {
+		sender, _ = curve25519.X25519(key, curve25519.Basepoint)
+	}
+	if len(sender) != keyLength {
+		return []byte{}, errors.New("sender's public key bust be provided")
+	}
+
+	var privateKey, senderPublicKey [keyLength]byte
+	copy(privateKey[:], key)
+	copy(senderPublicKey[:], sender)
+	if len(in) < 24 {
+		return []byte{}, errors.New("message is too short")
+	}
 	var nonce [24]byte
-	var senderPublicKey [32]byte
 	copy(nonce[:], in[:24])
-	copy(senderPublicKey[:], options.SenderPublicKey)
-	decrypted, ok := naclBox.Open(nil, in[24:], &nonce, &senderPublicKey, &b.privateKey)
+	decrypted, ok := naclBox.Open(nil, in[24:], &nonce, &senderPublicKey, &privateKey)
 	if !ok {
 		return []byte{}, errors.New("incoming message couldn't be verified / decrypted")
 	}
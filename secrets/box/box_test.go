@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/nextpkg/nextcfg/secrets"
+	"golang.org/x/crypto/curve25519"
 	naclBox "golang.org/x/crypto/nacl/box"
 )
 
@@ -64,3 +65,83 @@ func TestBox(t *testing.T) {
 		t.Errorf("Alice's decrypted message didn't match Bob's encrypted message %v != %v", bobSecret, dec)
 	}
 }
+
+func TestKeyring(t *testing.T) {
+	_, oldKey, err := naclBox.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newKey, err := naclBox.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr := secrets.NewKeyring()
+	if err = kr.Add("old", oldKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	app := NewSecrets(secrets.Keys(kr))
+	if err = app.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// encrypted for itself without a recipient
+	message := []byte("Why is a raven like a writing-desk?")
+	encOld, err := app.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = kr.Add("new", newKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err = kr.SetPrimary("new"); err != nil {
+		t.Fatal(err)
+	}
+	encNew, err := app.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enc := range [][]byte{encOld, encNew} {
+		if dec, err := app.Decrypt(enc); err != nil || !reflect.DeepEqual(dec, message) {
+			t.Errorf("Failed to decrypt with the keyring (%v)", err)
+		}
+	}
+
+	// encrypted by another party for the recipient's key id
+	alicePublicKey, alicePrivateKey, err := naclBox.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := NewSecrets(secrets.PublicKey(alicePublicKey[:]), secrets.PrivateKey(alicePrivateKey[:]))
+	if err = alice.Init(); err != nil {
+		t.Fatal(err)
+	}
+	appPublicKey, err := curve25519.X25519(oldKey[:], curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := alice.Encrypt(message, secrets.RecipientPublicKey(appPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = app.Decrypt(enc, secrets.SenderPublicKey(alicePublicKey[:])); err == nil {
+		t.Error("decrypted a message without key id and PrivateKey")
+	}
+
+	aliceKeys := secrets.NewKeyring()
+	if err = aliceKeys.Add("alice", alicePrivateKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err = alice.Init(secrets.Keys(aliceKeys)); err != nil {
+		t.Fatal(err)
+	}
+	enc, err = alice.Encrypt(message, secrets.RecipientPublicKey(appPublicKey), secrets.RecipientKeyID("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec, err := app.Decrypt(enc, secrets.SenderPublicKey(alicePublicKey[:])); err != nil || !reflect.DeepEqual(dec, message) {
+		t.Errorf("Failed to decrypt by the recipient's key id (%v)", err)
+	}
+}
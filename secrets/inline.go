@@ -0,0 +1,41 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	inlinePrefix = "ENC["
+	inlineSuffix = "]"
+)
+
+// Inline formats a ciphertext as an inline config value, e.g. ENC[secretbox,base64]
+func Inline(scheme string, ciphertext []byte) string {
+	return inlinePrefix + scheme + "," + base64.StdEncoding.EncodeToString(ciphertext) + inlineSuffix
+}
+
+// IsInline reports whether s looks like an inline encrypted value
+func IsInline(s string) bool {
+	return strings.HasPrefix(s, inlinePrefix) && strings.HasSuffix(s, inlineSuffix)
+}
+
+// ParseInline returns the scheme and the ciphertext of an inline encrypted value
+func ParseInline(s string) (string, []byte, error) {
+	if !IsInline(s) {
+		return "", nil, errors.New("not an inline encrypted value")
+	}
+
+	scheme, payload, ok := strings.Cut(s[len(inlinePrefix):len(s)-len(inlineSuffix)], ",")
+	if !ok || scheme == "" {
+		return "", nil, errors.New("inline encrypted value without scheme")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "bad inline encrypted value")
+	}
+	return scheme, ciphertext, nil
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// envelopeMagic starts the ciphertexts which carry the id of their key
+var envelopeMagic = []byte{'n', 'k', 1}
+
+// Keyring holds the keys by id, every key decrypts and the primary one encrypts.
+// It's safe to rotate the keys while the Secrets using it are in use.
+type Keyring struct {
+	sync.RWMutex
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyring returns an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string][]byte)}
+}
+
+// Add adds or replaces a key, the first key becomes the primary one
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return errors.Errorf("key id must be 1 to 255 bytes long: %q", id)
+	}
+	if len(key) == 0 {
+		return errors.Errorf("key %q is empty", id)
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	k.keys[id] = append([]byte(nil), key...)
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+// SetPrimary designates the key new values are encrypted with
+func (k *Keyring) SetPrimary(id string) error {
+	k.Lock()
+	defer k.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return errors.Errorf("unknown key id %q", id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Primary returns the id and the key new values are encrypted with
+func (k *Keyring) Primary() (string, []byte) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.primary, k.keys[k.primary]
+}
+
+// Get returns the key of the id
+func (k *Keyring) Get(id string) ([]byte, bool) {
+	k.RLock()
+	defer k.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Remove retires a key, the values encrypted with it can't be decrypted any more
+func (k *Keyring) Remove(id string) error {
+	k.Lock()
+	defer k.Unlock()
+
+	if id == k.primary {
+		return errors.Errorf("couldn't remove the primary key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// IDs returns the sorted key ids
+func (k *Keyring) IDs() []string {
+	k.RLock()
+	defer k.RUnlock()
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Envelope prefixes a ciphertext with the id of its key
+func Envelope(id string, ciphertext []byte) []byte {
+	b := make([]byte, 0, len(envelopeMagic)+1+len(id)+len(ciphertext))
+	b = append(b, envelopeMagic...)
+	b = append(b, byte(len(id)))
+	b = append(b, id...)
+	return append(b, ciphertext...)
+}
+
+// OpenEnvelope returns the key id and the ciphertext of an Envelope,
+// ok is false for the ciphertexts without key id
+func OpenEnvelope(b []byte) (id string, ciphertext []byte, ok bool) {
+	if !bytes.HasPrefix(b, envelopeMagic) || len(b) <= len(envelopeMagic) {
+		return "", b, false
+	}
+
+	n := int(b[len(envelopeMagic)])
+	start := len(envelopeMagic) + 1
+	if n == 0 || len(b) < start+n {
+		return "", b, false
+	}
+	return string(b[start : start+n]), b[start+n:], true
+}
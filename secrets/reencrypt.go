@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// inlinePattern matches the inline encrypted values in a config file of any format
+var inlinePattern = regexp.MustCompile(`ENC\[([^,\[\]\s]+),([A-Za-z0-9+/=]*)\]`)
+
+// Reencrypt rewrites the inline values of the scheme in data under the primary key of the keyring of s.
+// The rest of data is kept as is, so are the values already encrypted with the primary key.
+// n is the number of the rewritten values.
+func Reencrypt(data []byte, scheme string, s Secrets) (out []byte, n int, err error) {
+	var primary string
+	if kr := s.Options().Keyring; kr != nil {
+		primary, _ = kr.Primary()
+	}
+
+	out = inlinePattern.ReplaceAllFunc(data, func(m []byte) []byte {
+		if err != nil {
+			return m
+		}
+
+		sc, ciphertext, e := ParseInline(string(m))
+		if e != nil || sc != scheme {
+			return m
+		}
+		if id, _, ok := OpenEnvelope(ciphertext); ok && primary != "" && id == primary {
+			return m
+		}
+
+		plaintext, e := s.Decrypt(ciphertext)
+		if e != nil {
+			err = errors.Wrapf(e, "couldn't decrypt %.24s...", m)
+			return m
+		}
+		if ciphertext, e = s.Encrypt(plaintext); e != nil {
+			err = errors.Wrap(e, "couldn't encrypt")
+			return m
+		}
+
+		n++
+		return []byte(Inline(scheme, ciphertext))
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, n, nil
+}
+
+// ReencryptFile rewrites the inline values of the scheme of a config file in place, see Reencrypt
+func ReencryptFile(path, scheme string, s Secrets) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	out, n, err := Reencrypt(data, scheme, s)
+	if err != nil || n == 0 {
+		return 0, errors.Wrap(err, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// replaced atomically, the watchers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(out); err == nil {
+		err = tmp.Chmod(info.Mode())
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
@@ -33,6 +33,16 @@ func (s *secretBox) Init(opts ...secrets.Option) error {
 	for _, o := range opts {
 		o(&s.options)
 	}
+	if kr := s.options.Keyring; kr != nil {
+		for _, id := range kr.IDs() {
+			if key, _ := kr.Get(id); len(key) != keyLength {
+				return errors.Errorf("secret key %q must be %d bytes long", id, keyLength)
+			}
+		}
+		if len(s.options.Key) == 0 && len(kr.IDs()) > 0 {
+			return nil
+		}
+	}
 	if len(s.options.Key) == 0 {
 		return errors.New("no secret key is defined")
 	}
@@ -53,21 +63,61 @@ func (s *secretBox) String() string {
 	return "nacl-secretbox"
 }
 
-// Encrypt ...
+// Encrypt with the primary key of the keyring if any, the key id is embedded in the message
 func (s *secretBox) Encrypt(in []byte, _ ...secrets.EncryptOption) ([]byte, error) {
 	// there must be a unique nonce for each message
 	var nonce [24]byte
 	if _, err := rand.Reader.Read(nonce[:]); err != nil {
 		return []byte{}, errors.Wrap(err, "couldn't obtain a random nonce from crypto/rand")
 	}
+
+	if kr := s.options.Keyring; kr != nil {
+		if id, key := kr.Primary(); id != "" {
+			// the key may have been added after Init
+			if len(key) != keyLength {
+				return []byte{}, errors.Errorf("secret key %q must be %d bytes long", id, keyLength)
+			}
+			var primary [keyLength]byte
+			copy(primary[:], key)
+			return secrets.Envelope(id, secretbox.Seal(nonce[:], in, &nonce, &primary)), nil
+		}
+	}
+	if len(s.options.Key) != keyLength {
+		return []byte{}, errors.New("no secret key is defined")
+	}
 	return secretbox.Seal(nonce[:], in, &nonce, &s.secretKey), nil
 }
 
-// Decrypt ...
+// Decrypt with the key of the embedded key id, or with Key if there is none
 func (s *secretBox) Decrypt(in []byte, _ ...secrets.DecryptOption) ([]byte, error) {
+	if id, ciphertext, ok := secrets.OpenEnvelope(in); ok && s.options.Keyring != nil {
+		if key, found := s.options.Keyring.Get(id); found && len(key) == keyLength {
+			var k [keyLength]byte
+			copy(k[:], key)
+			if decrypted, err := open(ciphertext, &k); err == nil || len(s.options.Key) == 0 {
+				return decrypted, err
+			}
+		} else if found && len(s.options.Key) == 0 {
+			return []byte{}, errors.Errorf("secret key %q must be %d bytes long", id, keyLength)
+		} else if len(s.options.Key) == 0 {
+			return []byte{}, errors.Errorf("unknown key id %q", id)
+		}
+	}
+
+	// a message without key id may start like an envelope by chance
+	if len(s.options.Key) != keyLength {
+		return []byte{}, errors.New("no secret key is defined")
+	}
+	return open(in, &s.secretKey)
+}
+
+func open(in []byte, key *[keyLength]byte) ([]byte, error) {
+	if len(in) < 24 {
+		return []byte{}, errors.New("message is too short")
+	}
 	var decryptNonce [24]byte
 	copy(decryptNonce[:], in[:24])
-	decrypted, ok := secretbox.Open(nil, in[24:], &decryptNonce, &s.secretKey)
+	decrypted, ok := secretbox.Open(nil, in[24:], &decryptNonce, key)
 	if !ok {
 		return []byte{}, errors.New("decryption failed (is the key set correctly?)")
 	}
@@ -2,7 +2,10 @@ package secretbox
 
 import (
 	"encoding/base64"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/nextpkg/nextcfg/secrets"
@@ -54,3 +57,161 @@ func TestSecretBox(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyring(t *testing.T) {
+	old, new := make([]byte, keyLength), make([]byte, keyLength)
+	new[0] = 1
+
+	kr := secrets.NewKeyring()
+	if err := kr.Add("2023", old); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSecrets()
+	if err := s.Init(secrets.Keys(kr)); err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("Ground Control to Major Tom")
+	encOld, err := s.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, ok := secrets.OpenEnvelope(encOld); !ok || id != "2023" {
+		t.Errorf("key id %q not embedded", id)
+	}
+
+	// rotation: the new key encrypts, both keys decrypt
+	if err = kr.Add("2024", new); err != nil {
+		t.Fatal(err)
+	}
+	if err = kr.SetPrimary("2024"); err != nil {
+		t.Fatal(err)
+	}
+	encNew, err := s.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _ := secrets.OpenEnvelope(encNew); id != "2024" {
+		t.Errorf("expected key id 2024, got %q", id)
+	}
+	for _, enc := range [][]byte{encOld, encNew} {
+		if dec, err := s.Decrypt(enc); err != nil || !reflect.DeepEqual(dec, message) {
+			t.Errorf("Failed to decrypt with the keyring (%v)", err)
+		}
+	}
+
+	if err = kr.Remove("2024"); err == nil {
+		t.Error("the primary key was removed")
+	}
+	if err = kr.Remove("2023"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Decrypt(encOld); err == nil {
+		t.Error("decrypted with a removed key")
+	}
+
+	// the values without key id are decrypted by Key
+	legacy := NewSecrets(secrets.Key(old))
+	if err = legacy.Init(); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := legacy.Encrypt(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Init(secrets.Key(old)); err != nil {
+		t.Fatal(err)
+	}
+	if dec, err := s.Decrypt(enc); err != nil || !reflect.DeepEqual(dec, message) {
+		t.Errorf("Failed to decrypt a value without key id (%v)", err)
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	old, new := make([]byte, keyLength), make([]byte, keyLength)
+	new[0] = 1
+
+	legacy := NewSecrets(secrets.Key(old))
+	if err := legacy.Init(); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := legacy.Encrypt([]byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "db:\n  user: root\n  password: " + secrets.Inline("secretbox", enc) + "\nother: ENC[box,AAAA]\n"
+	if err = os.WriteFile(path, []byte(data), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	kr := secrets.NewKeyring()
+	if err = kr.Add("2024", new); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSecrets(secrets.Key(old), secrets.Keys(kr))
+	if err = s.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := secrets.ReencryptFile(path, "secretbox", s)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 value re-encrypted, got %d (%v)", n, err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(b), "\n")
+	if lines[1] != "  user: root" || lines[3] != "other: ENC[box,AAAA]" {
+		t.Errorf("unexpected rewrite:\n%s", b)
+	}
+
+	_, ct, err := secrets.ParseInline(strings.TrimPrefix(lines[2], "  password: "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _ := secrets.OpenEnvelope(ct); id != "2024" {
+		t.Errorf("expected key id 2024, got %q", id)
+	}
+	if dec, err := s.Decrypt(ct); err != nil || string(dec) != "s3cret" {
+		t.Errorf("Failed to decrypt the re-encrypted value (%v)", err)
+	}
+
+	// the values under the primary key are kept
+	if n, err = secrets.ReencryptFile(path, "secretbox", s); err != nil || n != 0 {
+		t.Errorf("expected no value re-encrypted, got %d (%v)", n, err)
+	}
+}
+
+func TestKeyringShortKey(t *testing.T) {
+	kr := secrets.NewKeyring()
+	if err := kr.Add("short", []byte("too short")); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSecrets(secrets.Keys(kr)).Init(); err == nil {
+		t.Error("Secretbox accepted a keyring key that is invalid")
+	}
+
+	// a key added after Init is not used with a zero key instead
+	kr = secrets.NewKeyring()
+	s := NewSecrets(secrets.Keys(kr))
+	if err := s.Init(); err == nil {
+		t.Error("Secretbox accepted an empty keyring")
+	}
+	if _, err := s.Encrypt([]byte("message")); err == nil {
+		t.Error("encrypted without a key")
+	}
+	if err := kr.Add("short", []byte("too short")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Encrypt([]byte("message")); err == nil {
+		t.Error("encrypted with a keyring key that is invalid")
+	}
+	if err := kr.Add("empty", nil); err == nil {
+		t.Error("the keyring accepted an empty key")
+	}
+}
@@ -25,6 +25,8 @@ type Options struct {
 	PrivateKey []byte
 	// Public key for encoding
 	PublicKey []byte
+	// Keyring holds the rotated keys by id, the primary one encrypts
+	Keyring *Keyring
 	// Context for other opts
 	Context context.Context
 }
@@ -56,6 +58,14 @@ func PrivateKey(key []byte) Option {
 	}
 }
 
+// Keys sets the keyring, the ciphertexts carry the id of the key they're encrypted with.
+// Key and PrivateKey still decrypt the ciphertexts without key id.
+func Keys(k *Keyring) Option {
+	return func(o *Options) {
+		o.Keyring = k
+	}
+}
+
 // DecryptOptions can be passed to Secrets.Decrypt
 type DecryptOptions struct {
 	SenderPublicKey []byte
@@ -75,6 +85,7 @@ func SenderPublicKey(key []byte) DecryptOption {
 // EncryptOptions can be passed to Secrets.Encrypt
 type EncryptOptions struct {
 	RecipientPublicKey []byte
+	RecipientKeyID     string
 }
 
 // EncryptOption Sets EncryptOptions
@@ -87,3 +98,10 @@ func RecipientPublicKey(key []byte) EncryptOption {
 		copy(e.RecipientPublicKey, key)
 	}
 }
+
+// RecipientKeyID is the id of the recipient's key in its keyring, it's embedded in the message
+func RecipientKeyID(id string) EncryptOption {
+	return func(e *EncryptOptions) {
+		e.RecipientKeyID = id
+	}
+}
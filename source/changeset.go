@@ -1,13 +1,101 @@
 package source
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// SHA256 checksum, usable for integrity checks
+	SHA256 = "sha256"
+	// XXHash checksum, fast but not collision resistant
+	XXHash = "xxhash"
+)
+
+// DefaultChecksum is the algorithm of the change sets without one
+var DefaultChecksum = SHA256
+
+var (
+	checksumMu sync.RWMutex
+	checksums  = map[string]func() hash.Hash{
+		SHA256: sha256.New,
+		XXHash: func() hash.Hash { return xxhash.New() },
+	}
 )
 
-// Sum returns the md5 checksum of the ChangeSet data
-func (c *ChangeSet) Sum() string {
-	h := md5.New()
-	h.Write(c.Data)
-	return fmt.Sprintf("%x", h.Sum(nil))
+// RegisterChecksum makes a checksum algorithm available by name
+func RegisterChecksum(name string, fn func() hash.Hash) {
+	checksumMu.Lock()
+	checksums[name] = fn
+	checksumMu.Unlock()
+}
+
+// Checksums returns the names of the registered algorithms
+func Checksums() []string {
+	checksumMu.RLock()
+	defer checksumMu.RUnlock()
+
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checksum returns the hex encoded checksum of data
+func Checksum(algorithm string, data []byte) (string, error) {
+	checksumMu.RLock()
+	fn, ok := checksums[algorithm]
+	checksumMu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown checksum algorithm %q", algorithm)
+	}
+
+	h := fn()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Sum returns the checksum of the ChangeSet data with its algorithm, DefaultChecksum if it's empty.
+// An algorithm which is not registered is an error, the sources labeling their own checksums,
+// e.g. the commit hashes of git, set Checksum themselves.
+func (c *ChangeSet) Sum() (string, error) {
+	algorithm := c.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultChecksum
+	}
+	return Checksum(algorithm, c.Data)
+}
+
+// Verify checks the ChangeSet data against the expected digest, e.g. the content of a sha256sum file
+func (c *ChangeSet) Verify(expected string) error {
+	algorithm := c.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultChecksum
+	}
+
+	sum, err := Checksum(algorithm, c.Data)
+	if err != nil {
+		return err
+	}
+
+	// sha256sum style: the digest is followed by the file name
+	fields := strings.Fields(expected)
+	if len(fields) == 0 {
+		return fmt.Errorf("%s: no %s digest to verify", c.Source, algorithm)
+	}
+
+	if !strings.EqualFold(fields[0], sum) {
+		return fmt.Errorf("%s: %w, expected %s %s got %s", c.Source, ErrChecksumMismatch, algorithm, fields[0], sum)
+	}
+	return nil
 }
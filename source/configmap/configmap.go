@@ -75,11 +75,14 @@ func (k *configmap) Read() (*source.ChangeSet, error) {
 	cs := &source.ChangeSet{
 		Format:    k.format,
 		Source:    k.String(),
+		Algorithm: k.opts.Checksum,
 		Data:      []byte(data),
 		Timestamp: cmp.CreationTimestamp.Time,
 	}
 
-	cs.Checksum = cs.Sum()
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
 
 	return cs, nil
 }
@@ -91,7 +94,10 @@ func (k *configmap) Write(*source.ChangeSet) error {
 
 // String ...
 func (k *configmap) String() string {
-	return sourceName
+	if k.opts.Name != "" {
+		return k.opts.Name
+	}
+	return sourceName + ":" + k.namespace + "/" + k.name
 }
 
 // Watch 监控配置变化
@@ -100,7 +106,7 @@ func (k *configmap) Watch() (source.Watcher, error) {
 		return nil, k.err
 	}
 
-	return newWatcher(k.group, k.name, k.namespace, k.format, k.client)
+	return newWatcher(k.String(), k.opts.Checksum, k.group, k.name, k.namespace, k.format, k.client)
 }
 
 // NewSource is a factory function
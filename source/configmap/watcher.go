@@ -12,6 +12,8 @@ import (
 )
 
 type watcher struct {
+	source    string
+	checksum  string
 	group     string
 	name      string
 	namespace string
@@ -25,8 +27,10 @@ type watcher struct {
 	stop chan struct{}
 }
 
-func newWatcher(group, name, ns, format string, c *kubernetes.Clientset) (source.Watcher, error) {
+func newWatcher(src, checksum, group, name, ns, format string, c *kubernetes.Clientset) (source.Watcher, error) {
 	w := &watcher{
+		source:    src,
+		checksum:  checksum,
 		group:     group,
 		name:      name,
 		namespace: ns,
@@ -64,11 +68,16 @@ func (w *watcher) handle(_ interface{}, newCmp interface{}) {
 	cm := newCmp.(*v12.ConfigMap)
 	cs := &source.ChangeSet{
 		Format:    w.format,
-		Source:    w.name,
+		Source:    w.source,
+		Algorithm: w.checksum,
 		Data:      []byte(cm.Data[w.name]),
 		Timestamp: cm.CreationTimestamp.Time,
 	}
-	cs.Checksum = cs.Sum()
+	sum, err := cs.Sum()
+	if err != nil {
+		return
+	}
+	cs.Checksum = sum
 
 	w.ch <- cs
 }
@@ -2,16 +2,16 @@ package consul
 
 import (
 	"fmt"
-	"github.com/nextpkg/nextcfg"
+	"log"
 	"net"
 	"time"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/nextpkg/nextcfg"
 	"github.com/nextpkg/nextcfg/cmd"
-	"github.com/nextpkg/nextcfg/hub"
+	"github.com/nextpkg/nextcfg/registry"
 	"github.com/nextpkg/nextcfg/source"
 	"github.com/spf13/pflag"
-	"log"
 )
 
 // Currently a single consul reader
@@ -35,11 +35,11 @@ var (
 const sourceName = "consul"
 
 func init() {
-	hub.SetDefaultSource(sourceName)
+	registry.SetCfgSource(sourceName)
 
-	// 此处依赖于hub的初始化参数--source
-	cmd.AddSubFlags(hub.SourceFlag, sourceName, func() *pflag.FlagSet {
-		fs := pflag.NewFlagSet("--source=consul", pflag.ContinueOnError)
+	// 此处依赖于registry的初始化参数--cfg
+	cmd.AddSubFlags(registry.CfgFlag, sourceName, func() *cmd.FlagSet {
+		fs := cmd.NewFlagSet("--cfg=consul", pflag.ContinueOnError)
 		fs.StringVar(&DefaultPrefix, "config_prefix", DefaultPrefix, "consul system prefix")
 		fs.StringVar(&DefaultAddress, "config_address", DefaultAddress, "consul system address")
 		fs.StringVar(&DefaultDatacenter, "config_datacenter", DefaultDatacenter, "consul system datacenter")
@@ -47,7 +47,7 @@ func init() {
 		return fs
 	})
 
-	hub.SetToHub(sourceName, func(target string) nextcfg.Loader {
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
 		return func(l *nextcfg.Loaders) {
 			var opts = []source.Option{
 				WithPrefix(DefaultPrefix),
@@ -91,9 +91,12 @@ func (c *consul) Read() (*source.ChangeSet, error) {
 		Timestamp: time.Now(),
 		Format:    c.opts.Encoder.String(),
 		Source:    c.String(),
+		Algorithm: c.opts.Checksum,
 		Data:      b,
 	}
-	cs.Checksum = cs.Sum()
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
 
 	return cs, nil
 }
@@ -105,12 +108,15 @@ func (c *consul) Write(*source.ChangeSet) error {
 
 // String consul
 func (c *consul) String() string {
-	return sourceName
+	if c.opts.Name != "" {
+		return c.opts.Name
+	}
+	return sourceName + ":" + c.prefix
 }
 
 // Watch change
 func (c *consul) Watch() (source.Watcher, error) {
-	w, err := newWatcher(c.prefix, c.addr, c.String(), c.stripPrefix, c.opts.Encoder)
+	w, err := newWatcher(c.prefix, c.addr, c.String(), c.stripPrefix, c.opts.Checksum, c.opts.Encoder)
 	if err != nil {
 		return nil, err
 	}
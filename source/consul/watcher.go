@@ -14,16 +14,18 @@ type watcher struct {
 	e           encoder.Encoder
 	name        string
 	stripPrefix string
+	checksum    string
 
 	wp   *watch.Plan
 	ch   chan *source.ChangeSet
 	exit chan bool
 }
 
-func newWatcher(key, addr, name, stripPrefix string, e encoder.Encoder) (source.Watcher, error) {
+func newWatcher(key, addr, name, stripPrefix, checksum string, e encoder.Encoder) (source.Watcher, error) {
 	w := &watcher{
 		e:           e,
 		name:        name,
+		checksum:    checksum,
 		stripPrefix: stripPrefix,
 		ch:          make(chan *source.ChangeSet),
 		exit:        make(chan bool),
@@ -72,9 +74,12 @@ func (w *watcher) handle(idx uint64, data interface{}) {
 		Timestamp: time.Now(),
 		Format:    w.e.String(),
 		Source:    w.name,
+		Algorithm: w.checksum,
 		Data:      b,
 	}
-	cs.Checksum = cs.Sum()
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return
+	}
 
 	w.ch <- cs
 }
@@ -0,0 +1,273 @@
+// Package dir merges the config files of a directory, e.g. conf.d
+package dir
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/registry"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/nextpkg/nextcfg/source/file/format"
+)
+
+var (
+	// DefaultPath 默认目录
+	DefaultPath = "conf.d"
+	// DefaultDebounce is how long the directory must be quiet before it's read again
+	DefaultDebounce = 100 * time.Millisecond
+)
+
+const sourceName = "dir"
+
+func init() {
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
+		if target == "" {
+			target = DefaultPath
+		}
+		return GetLoader(target)
+	})
+}
+
+type dir struct {
+	path      string
+	include   []string
+	exclude   []string
+	order     Order
+	recursive bool
+	debounce  time.Duration
+	opts      source.Options
+	// the checksum of the last read
+	sum atomic.Value
+}
+
+// entry is a matched file
+type entry struct {
+	rel  string
+	path string
+}
+
+// Read decodes and merges the matched files in order
+func (d *dir) Read() (*source.ChangeSet, error) {
+	entries, err := d.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest time.Time
+	merged := make(map[string]interface{})
+	for _, e := range entries {
+		b, err := os.ReadFile(e.path)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(e.path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+
+		v, err := format.Decode(e.rel, b, d.opts.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		format.Merge(merged, v)
+	}
+
+	b, err := d.opts.Encoder.Encode(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &source.ChangeSet{
+		Format:    d.opts.Encoder.String(),
+		Source:    d.String(),
+		Algorithm: d.opts.Checksum,
+		Timestamp: latest,
+		Data:      b,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
+	d.sum.Store(cs.Checksum)
+
+	return cs, nil
+}
+
+// files returns the matched files in order.
+// The hidden entries are skipped, e.g. the ..data directory of the kubernetes volumes, whose files are
+// reached through the symlinks next to it.
+func (d *dir) files() ([]entry, error) {
+	var entries []entry
+	err := d.walk(func(p, rel string, info os.FileInfo) error {
+		if !info.IsDir() && d.match(rel) {
+			entries = append(entries, entry{rel: rel, path: p})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return d.less(entries[i].rel, entries[j].rel)
+	})
+	return entries, nil
+}
+
+// walk calls fn with the entries of the directory, and of the sub directories if recursive.
+// Unlike filepath.WalkDir the symlinks are followed, to the sub directories too, each directory is
+// visited once so a symlink loop ends.
+func (d *dir) walk(fn func(p, rel string, info os.FileInfo) error) error {
+	// the directory may be a symlink itself
+	root, err := filepath.EvalSymlinks(d.path)
+	if err != nil {
+		return err
+	}
+	return d.walkDir(root, "", map[string]bool{root: true}, fn)
+}
+
+func (d *dir) walkDir(p, rel string, seen map[string]bool, fn func(p, rel string, info os.FileInfo) error) error {
+	des, err := os.ReadDir(p)
+	if err != nil {
+		return err
+	}
+
+	for _, de := range des {
+		if strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+
+		sub, subRel := filepath.Join(p, de.Name()), path.Join(rel, de.Name())
+		info, err := os.Stat(sub)
+		if err != nil {
+			// a dangling symlink in the middle of a swap
+			continue
+		}
+		if err = fn(sub, subRel, info); err != nil {
+			return err
+		}
+		if !info.IsDir() || !d.recursive {
+			continue
+		}
+
+		target, err := filepath.EvalSymlinks(sub)
+		if err != nil || seen[target] {
+			continue
+		}
+		seen[target] = true
+		if err = d.walkDir(sub, subRel, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match checks the patterns against the name and the relative path
+func (d *dir) match(rel string) bool {
+	matches := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, rel); ok {
+				return true
+			}
+			if ok, _ := path.Match(p, path.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if matches(d.exclude) {
+		return false
+	}
+	if len(d.include) == 0 {
+		return format.Supported(rel)
+	}
+	return matches(d.include)
+}
+
+func (d *dir) less(a, b string) bool {
+	if d.order == Numeric {
+		na, oka := prefix(path.Base(a))
+		nb, okb := prefix(path.Base(b))
+		switch {
+		case oka && okb && na != nb:
+			return na < nb
+		case oka != okb:
+			return oka
+		}
+	}
+	return a < b
+}
+
+// prefix returns the number the name starts with
+func prefix(name string) (int, bool) {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(name[:i])
+	return n, err == nil
+}
+
+// String dir
+func (d *dir) String() string {
+	if d.opts.Name != "" {
+		return d.opts.Name
+	}
+	return sourceName + ":" + d.path
+}
+
+// Watch watches the directory for adds, removes, renames and symlink swaps
+func (d *dir) Watch() (source.Watcher, error) {
+	if _, err := os.Stat(d.path); err != nil {
+		return nil, err
+	}
+	return newWatcher(d)
+}
+
+// Write is unsupported
+func (d *dir) Write(*source.ChangeSet) error {
+	return nil
+}
+
+// NewSource 目录数据源
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	d := &dir{
+		path:     DefaultPath,
+		debounce: DefaultDebounce,
+		opts:     options,
+	}
+
+	if p, ok := options.Context.Value(dirPathKey{}).(string); ok && p != "" {
+		d.path = filepath.Clean(p)
+	}
+	d.include, _ = options.Context.Value(includeKey{}).([]string)
+	d.exclude, _ = options.Context.Value(excludeKey{}).([]string)
+	d.order, _ = options.Context.Value(orderKey{}).(Order)
+	d.recursive, _ = options.Context.Value(recursiveKey{}).(bool)
+	if t, ok := options.Context.Value(debounceKey{}).(time.Duration); ok && t > 0 {
+		d.debounce = t
+	}
+
+	return d
+}
+
+// GetLoader sets dir source
+func GetLoader(path string, opts ...source.Option) nextcfg.Loader {
+	return func(l *nextcfg.Loaders) {
+		err := l.GetCfg().Load(NewSource(append([]source.Option{WithPath(path)}, opts...)...))
+		if err != nil {
+			log.Println(err)
+		} else {
+			l.GetCfg().SetState(true)
+		}
+	}
+}
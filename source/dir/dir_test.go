@@ -0,0 +1,122 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/require"
+)
+
+func write(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.Nil(t, os.WriteFile(p, []byte(data), 0o644))
+	}
+}
+
+func TestDir(t *testing.T) {
+	at := require.New(t)
+
+	dir := t.TempDir()
+	write(t, dir, map[string]string{
+		"10-local.yaml": "db:\n  host: local\n",
+		"2-db.json":     `{"db": {"host": "db", "port": 3306}}`,
+		"base.toml":     "name = \"app\"\n[db]\nhost = \"base\"\n",
+		"sub/x.json":    `{"sub": true}`,
+		".hidden.json":  `{"hidden": true}`,
+		"README.md":     "# conf.d",
+	})
+
+	cs, err := NewSource(WithPath(dir)).Read()
+	at.Nil(err)
+	at.JSONEq(`{"name": "app", "db": {"host": "base", "port": 3306}}`, string(cs.Data))
+
+	cs, err = NewSource(WithPath(dir), WithOrder(Numeric)).Read()
+	at.Nil(err)
+	at.JSONEq(`{"name": "app", "db": {"host": "base", "port": 3306}}`, string(cs.Data))
+
+	cs, err = NewSource(WithPath(dir), WithOrder(Numeric), WithExclude("base.*")).Read()
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "local", "port": 3306}}`, string(cs.Data))
+
+	cs, err = NewSource(WithPath(dir), WithInclude("*.json"), Recursive()).Read()
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "db", "port": 3306}, "sub": true}`, string(cs.Data))
+
+	_, err = NewSource(WithPath(dir), WithInclude("*.md")).Read()
+	at.NotNil(err)
+}
+
+func TestSymlinkedDir(t *testing.T) {
+	at := require.New(t)
+
+	shared, dir := t.TempDir(), t.TempDir()
+	write(t, shared, map[string]string{"db.json": `{"db": {"host": "shared"}}`})
+	write(t, dir, map[string]string{"base.json": `{"name": "app"}`})
+	at.Nil(os.Symlink(shared, filepath.Join(dir, "shared")))
+	// a loop is read once
+	at.Nil(os.Symlink(dir, filepath.Join(dir, "loop")))
+
+	cs, err := NewSource(WithPath(dir)).Read()
+	at.Nil(err)
+	at.JSONEq(`{"name": "app"}`, string(cs.Data))
+
+	cs, err = NewSource(WithPath(dir), Recursive()).Read()
+	at.Nil(err)
+	at.JSONEq(`{"name": "app", "db": {"host": "shared"}}`, string(cs.Data))
+}
+
+func TestWatch(t *testing.T) {
+	at := require.New(t)
+
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"a.json": `{"a": 1}`})
+
+	// a kubernetes configmap volume: config.yaml -> ..data/config.yaml, ..data -> ..v1
+	write(t, dir, map[string]string{"..v1/config.yaml": "b: 1\n"})
+	at.Nil(os.Symlink("..v1", filepath.Join(dir, "..data")))
+	at.Nil(os.Symlink(filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml")))
+
+	src := NewSource(WithPath(dir), WithDebounce(20*time.Millisecond))
+	cs, err := src.Read()
+	at.Nil(err)
+	at.JSONEq(`{"a": 1, "b": 1}`, string(cs.Data))
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	next := func() *source.ChangeSet {
+		ch := make(chan *source.ChangeSet, 1)
+		go func() {
+			cs, _ := w.Next()
+			ch <- cs
+		}()
+		select {
+		case cs := <-ch:
+			return cs
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch timeout")
+		}
+		return nil
+	}
+
+	// add
+	write(t, dir, map[string]string{"c.json": `{"c": 1}`})
+	at.JSONEq(`{"a": 1, "b": 1, "c": 1}`, string(next().Data))
+
+	// remove
+	at.Nil(os.Remove(filepath.Join(dir, "c.json")))
+	at.JSONEq(`{"a": 1, "b": 1}`, string(next().Data))
+
+	// the atomic swap of the ..data symlink
+	write(t, dir, map[string]string{"..v2/config.yaml": "b: 2\n"})
+	at.Nil(os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	at.Nil(os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	at.Nil(os.RemoveAll(filepath.Join(dir, "..v1")))
+	at.JSONEq(`{"a": 1, "b": 2}`, string(next().Data))
+}
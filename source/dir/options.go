@@ -0,0 +1,87 @@
+package dir
+
+import (
+	"context"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// Order of the files, the later files override the earlier ones
+type Order int
+
+const (
+	// Lexical orders by the path
+	Lexical Order = iota
+	// Numeric orders by the number the file name starts with, e.g. 2-db.yaml before 10-local.yaml.
+	// The files without number go last.
+	Numeric
+)
+
+type dirPathKey struct{}
+type includeKey struct{}
+type excludeKey struct{}
+type orderKey struct{}
+type recursiveKey struct{}
+type debounceKey struct{}
+
+// WithPath sets the directory
+func WithPath(p string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, dirPathKey{}, p)
+	}
+}
+
+// WithInclude adds the glob patterns of the files to load, matched against the name and the relative path.
+// Defaults to the files of the known formats.
+func WithInclude(patterns ...string) source.Option {
+	return withPatterns(includeKey{}, patterns)
+}
+
+// WithExclude adds the glob patterns of the files to skip
+func WithExclude(patterns ...string) source.Option {
+	return withPatterns(excludeKey{}, patterns)
+}
+
+func withPatterns(key interface{}, patterns []string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		prev, _ := o.Context.Value(key).([]string)
+		o.Context = context.WithValue(o.Context, key, append(prev[:len(prev):len(prev)], patterns...))
+	}
+}
+
+// WithOrder sets the order the files are merged in, defaults to Lexical
+func WithOrder(order Order) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, orderKey{}, order)
+	}
+}
+
+// Recursive loads the files of the sub directories too
+func Recursive() source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, recursiveKey{}, true)
+	}
+}
+
+// WithDebounce sets how long the directory must be quiet before it's read again, defaults to 100ms
+func WithDebounce(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, debounceKey{}, d)
+	}
+}
@@ -0,0 +1,129 @@
+package dir
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type watcher struct {
+	d    *dir
+	fw   *fsnotify.Watcher
+	last string
+	ch   chan *source.ChangeSet
+	exit chan bool
+}
+
+func newWatcher(d *dir) (source.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		d:    d,
+		fw:   fw,
+		ch:   make(chan *source.ChangeSet),
+		exit: make(chan bool),
+	}
+
+	if err = w.add(d.path); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+
+	// the checksum the config is loaded with, a read which changes nothing isn't sent
+	w.last, _ = d.sum.Load().(string)
+
+	go w.run()
+
+	return w, nil
+}
+
+// add watches the directory, and its sub directories if recursive, the symlinked ones too
+func (w *watcher) add(root string) error {
+	if err := w.fw.Add(root); err != nil || !w.d.recursive {
+		return err
+	}
+
+	return w.d.walkDir(root, "", map[string]bool{}, func(p, _ string, info os.FileInfo) error {
+		if !info.IsDir() {
+			return nil
+		}
+		return w.fw.Add(p)
+	})
+}
+
+func (w *watcher) run() {
+	// a read is scheduled after every event and postponed by the next one,
+	// so a ..data symlink swap is read once it's done
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-w.exit:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			if w.d.recursive && ev.Has(fsnotify.Create) && !strings.HasPrefix(filepath.Base(ev.Name), ".") {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err = w.add(ev.Name); err != nil {
+						slog.Warn("dir watch failed.", slog.String("path", ev.Name), slog.String("err", err.Error()))
+					}
+				}
+			}
+			timer = time.After(w.d.debounce)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("dir watch failed.", slog.String("source", w.d.String()), slog.String("err", err.Error()))
+		case <-timer:
+			timer = nil
+
+			cs, err := w.d.Read()
+			if err != nil {
+				slog.Warn("dir read failed.", slog.String("source", w.d.String()), slog.String("err", err.Error()))
+				continue
+			}
+			if cs.Checksum == w.last {
+				continue
+			}
+			w.last = cs.Checksum
+
+			select {
+			case w.ch <- cs:
+			case <-w.exit:
+				return
+			}
+		}
+	}
+}
+
+// Next ...
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.ch:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop ...
+func (w *watcher) Stop() error {
+	select {
+	case <-w.exit:
+		return nil
+	default:
+		close(w.exit)
+	}
+	return w.fw.Close()
+}
@@ -2,6 +2,7 @@ package env
 
 import (
 	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/registry"
 	"log"
 	"os"
 	"strconv"
@@ -26,8 +27,8 @@ type env struct {
 const sourceName = "env"
 
 func init() {
-	registry.SetDefaultSource(sourceName)
-	registry.SetToHub(sourceName, func(target string) nextcfg.Loader {
+	registry.SetCfgSource(sourceName)
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
 		return GetLoader()
 	})
 }
@@ -91,8 +92,11 @@ func (e *env) Read() (*source.ChangeSet, error) {
 		Data:      b,
 		Timestamp: time.Now(),
 		Source:    e.String(),
+		Algorithm: e.opts.Checksum,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
 	}
-	cs.Checksum = cs.Sum()
 
 	return cs, nil
 }
@@ -126,6 +130,9 @@ func (e *env) Write(*source.ChangeSet) error {
 
 // String env
 func (e *env) String() string {
+	if e.opts.Name != "" {
+		return e.opts.Name
+	}
 	return sourceName
 }
 
@@ -0,0 +1,235 @@
+// Package etcd reads the config from the keys under a prefix of etcd v3
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/cmd"
+	"github.com/nextpkg/nextcfg/registry"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/spf13/pflag"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type etcd struct {
+	prefix      string
+	stripPrefix string
+	opts        source.Options
+	client      *clientv3.Client
+	err         error
+	// the revision of the last read
+	rev atomic.Int64
+}
+
+// Predefined variables
+var (
+	// DefaultPrefix is the prefix that etcd keys will be assumed to have if you
+	// haven't specified one
+	DefaultPrefix      = "/nextcfg/"
+	DefaultEndpoints   = "127.0.0.1:2379"
+	DefaultUsername    = ""
+	DefaultPassword    = ""
+	DefaultCertFile    = ""
+	DefaultKeyFile     = ""
+	DefaultCAFile      = ""
+	DefaultStripPrefix = false
+	DefaultDialTimeout = 5 * time.Second
+)
+
+const sourceName = "etcd"
+
+func init() {
+	registry.SetCfgSource(sourceName)
+
+	// 此处依赖于registry的初始化参数--cfg
+	cmd.AddSubFlags(registry.CfgFlag, sourceName, func() *cmd.FlagSet {
+		fs := cmd.NewFlagSet("--cfg=etcd", pflag.ContinueOnError)
+		fs.StringVar(&DefaultPrefix, "config_prefix", DefaultPrefix, "etcd key prefix")
+		fs.StringVar(&DefaultEndpoints, "config_endpoints", DefaultEndpoints, "etcd endpoints, comma separated")
+		fs.StringVar(&DefaultUsername, "config_username", DefaultUsername, "etcd username")
+		fs.StringVar(&DefaultPassword, "config_password", DefaultPassword, "etcd password")
+		fs.StringVar(&DefaultCertFile, "config_cert", DefaultCertFile, "etcd client cert file")
+		fs.StringVar(&DefaultKeyFile, "config_key", DefaultKeyFile, "etcd client key file")
+		fs.StringVar(&DefaultCAFile, "config_ca", DefaultCAFile, "etcd ca file")
+		fs.BoolVar(&DefaultStripPrefix, "config_strip_prefix", DefaultStripPrefix, "strip the prefix from the keys")
+		return fs
+	})
+
+	// 注册，--cfg=etcd的参数是key前缀
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
+		if target == "" {
+			target = DefaultPrefix
+		}
+
+		opts := []source.Option{
+			WithEndpoints(strings.Split(DefaultEndpoints, ",")...),
+			StripPrefix(DefaultStripPrefix),
+		}
+		if DefaultUsername != "" {
+			opts = append(opts, Auth(DefaultUsername, DefaultPassword))
+		}
+		if DefaultCertFile != "" || DefaultCAFile != "" {
+			t, err := transport.TLSInfo{
+				CertFile:      DefaultCertFile,
+				KeyFile:       DefaultKeyFile,
+				TrustedCAFile: DefaultCAFile,
+			}.ClientConfig()
+			if err != nil {
+				log.Println(err)
+			}
+			opts = append(opts, WithTLS(t))
+		}
+		return GetLoader(target, opts...)
+	})
+}
+
+// Read the keys under the prefix
+func (e *etcd) Read() (*source.ChangeSet, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	ctx, cancel := context.WithTimeout(e.opts.Context, DefaultDialTimeout)
+	defer cancel()
+
+	rsp, err := e.client.Get(ctx, e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rsp.Kvs) == 0 {
+		return nil, fmt.Errorf("source not found: %s", e.prefix)
+	}
+
+	e.rev.Store(rsp.Header.Revision)
+	return e.changeSet(rsp.Kvs)
+}
+
+// changeSet encodes the tree of the keys
+func (e *etcd) changeSet(kvs []*mvccpb.KeyValue) (*source.ChangeSet, error) {
+	data, err := makeMap(e.opts.Encoder, kvs, e.stripPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data: %v", err)
+	}
+
+	b, err := e.opts.Encoder.Encode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading source: %v", err)
+	}
+
+	cs := &source.ChangeSet{
+		Timestamp: time.Now(),
+		Format:    e.opts.Encoder.String(),
+		Source:    e.String(),
+		Algorithm: e.opts.Checksum,
+		Data:      b,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// Write is unsupported
+func (e *etcd) Write(*source.ChangeSet) error {
+	return nil
+}
+
+// String etcd
+func (e *etcd) String() string {
+	if e.opts.Name != "" {
+		return e.opts.Name
+	}
+	return sourceName + ":" + e.prefix
+}
+
+// Watch the keys under the prefix from the revision of the last read
+func (e *etcd) Watch() (source.Watcher, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return newWatcher(e)
+}
+
+// NewSource creates a new etcd source
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	cfg := clientv3.Config{
+		Endpoints:   strings.Split(DefaultEndpoints, ","),
+		DialTimeout: DefaultDialTimeout,
+	}
+
+	// use the etcd config passed in the options if any
+	if c, ok := options.Context.Value(configKey{}).(clientv3.Config); ok {
+		cfg = c
+	}
+
+	if e, ok := options.Context.Value(endpointsKey{}).([]string); ok && len(e) > 0 {
+		cfg.Endpoints = e
+	}
+
+	if a, ok := options.Context.Value(authKey{}).(*auth); ok {
+		cfg.Username = a.username
+		cfg.Password = a.password
+	}
+
+	if t, ok := options.Context.Value(tlsKey{}).(*tls.Config); ok {
+		cfg.TLS = t
+	}
+
+	if d, ok := options.Context.Value(dialTimeoutKey{}).(time.Duration); ok {
+		cfg.DialTimeout = d
+	}
+
+	prefix := DefaultPrefix
+	if p, ok := options.Context.Value(prefixKey{}).(string); ok {
+		prefix = p
+	}
+
+	sp := ""
+	if b, ok := options.Context.Value(stripPrefixKey{}).(bool); ok && b {
+		sp = prefix
+	}
+
+	// the client connects lazily, the errors are reported by Read and Watch
+	client, err := clientv3.New(cfg)
+
+	return &etcd{
+		prefix:      prefix,
+		stripPrefix: sp,
+		opts:        options,
+		client:      client,
+		err:         err,
+	}
+}
+
+// GetLoader sets etcd source
+func GetLoader(prefix string, opts ...source.Option) nextcfg.Loader {
+	return func(l *nextcfg.Loaders) {
+		options := []source.Option{
+			WithPrefix(prefix),
+		}
+
+		if len(opts) > 0 {
+			options = append(options, opts...)
+		}
+
+		err := l.GetCfg().Load(NewSource(options...))
+		if err != nil {
+			log.Println(err)
+		} else {
+			l.GetCfg().SetState(true)
+		}
+	}
+}
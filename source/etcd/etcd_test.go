@@ -0,0 +1,139 @@
+package etcd
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// startEtcd runs an embedded single node etcd on a random port
+func startEtcd(t *testing.T) string {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+
+	lc, _ := url.Parse("http://127.0.0.1:0")
+	cfg.ListenClientUrls = []url.URL{*lc}
+	cfg.ListenPeerUrls = []url.URL{*lc}
+	cfg.AdvertiseClientUrls = []url.URL{*lc}
+	cfg.AdvertisePeerUrls = []url.URL{*lc}
+	cfg.InitialCluster = cfg.Name + "=" + lc.String()
+
+	e, err := embed.StartEtcd(cfg)
+	require.Nil(t, err)
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("etcd start timeout")
+	}
+	return e.Clients[0].Addr().String()
+}
+
+func TestEtcd(t *testing.T) {
+	at := require.New(t)
+
+	addr := startEtcd(t)
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{addr}})
+	at.Nil(err)
+	defer cli.Close()
+
+	ctx := context.Background()
+	_, err = cli.Put(ctx, "/nextcfg/database", `{"address": "10.0.0.1", "port": 3306}`)
+	at.Nil(err)
+	_, err = cli.Put(ctx, "/nextcfg/hosts", `["a", "b"]`)
+	at.Nil(err)
+
+	src := NewSource(WithEndpoints(addr), WithPrefix("/nextcfg/"), StripPrefix(true))
+	cs, err := src.Read()
+	at.Nil(err)
+	at.Equal("etcd:/nextcfg/", cs.Source)
+	at.JSONEq(`{"database": {"address": "10.0.0.1", "port": 3306}, "hosts": ["a", "b"]}`, string(cs.Data))
+
+	// a change between the read and the watch is not missed
+	_, err = cli.Put(ctx, "/nextcfg/cache", `{"port": 6379}`)
+	at.Nil(err)
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	next := func() *source.ChangeSet {
+		ch := make(chan *source.ChangeSet, 1)
+		go func() {
+			cs, err := w.Next()
+			at.Nil(err)
+			ch <- cs
+		}()
+		select {
+		case cs := <-ch:
+			return cs
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch timeout")
+		}
+		return nil
+	}
+
+	at.JSONEq(`{"database": {"address": "10.0.0.1", "port": 3306}, "hosts": ["a", "b"], "cache": {"port": 6379}}`,
+		string(next().Data))
+
+	_, err = cli.Delete(ctx, "/nextcfg/hosts")
+	at.Nil(err)
+	at.JSONEq(`{"database": {"address": "10.0.0.1", "port": 3306}, "cache": {"port": 6379}}`, string(next().Data))
+}
+
+func TestWatchResume(t *testing.T) {
+	at := require.New(t)
+
+	addr := startEtcd(t)
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{addr}})
+	at.Nil(err)
+	defer cli.Close()
+
+	ctx := context.Background()
+	_, err = cli.Put(ctx, "/app/port", `[80]`)
+	at.Nil(err)
+
+	e := NewSource(WithEndpoints(addr), WithPrefix("/app/"), StripPrefix(true)).(*etcd)
+	retryInterval = 10 * time.Millisecond
+
+	// a watcher which has seen the keys, but is disconnected until run
+	paused := func() *watcher {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := &watcher{e: e, ctx: ctx, cancel: cancel, ch: make(chan *source.ChangeSet), exit: make(chan bool)}
+		at.Nil(w.sync())
+		t.Cleanup(func() { _ = w.Stop() })
+		return w
+	}
+
+	// the changes while disconnected are caught up from the revision
+	w := paused()
+	_, err = cli.Put(ctx, "/app/port", `[8080]`)
+	at.Nil(err)
+	go w.run()
+
+	cs, err := w.Next()
+	at.Nil(err)
+	at.JSONEq(`{"port": [8080]}`, string(cs.Data))
+
+	// the revision is compacted while disconnected, the keys are loaded again
+	w = paused()
+	_, err = cli.Put(ctx, "/app/port", `[8081]`)
+	at.Nil(err)
+	rsp, err := cli.Put(ctx, "/app/port", `[8082]`)
+	at.Nil(err)
+	_, err = cli.Compact(ctx, rsp.Header.Revision)
+	at.Nil(err)
+	go w.run()
+
+	cs, err = w.Next()
+	at.Nil(err)
+	at.JSONEq(`{"port": [8082]}`, string(cs.Data))
+}
@@ -0,0 +1,93 @@
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type endpointsKey struct{}
+type prefixKey struct{}
+type stripPrefixKey struct{}
+type authKey struct{}
+type tlsKey struct{}
+type dialTimeoutKey struct{}
+type configKey struct{}
+
+type auth struct {
+	username string
+	password string
+}
+
+// WithEndpoints sets the etcd endpoints, defaults to 127.0.0.1:2379
+func WithEndpoints(e ...string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, endpointsKey{}, e)
+	}
+}
+
+// WithPrefix sets the key prefix to use
+func WithPrefix(p string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, prefixKey{}, p)
+	}
+}
+
+// StripPrefix indicates whether to remove the prefix from config entries, or leave it in place.
+func StripPrefix(strip bool) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, stripPrefixKey{}, strip)
+	}
+}
+
+// Auth sets the username and the password of etcd
+func Auth(username, password string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, authKey{}, &auth{username: username, password: password})
+	}
+}
+
+// WithTLS sets the tls config of the etcd client
+func WithTLS(t *tls.Config) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, tlsKey{}, t)
+	}
+}
+
+// WithDialTimeout sets the dial timeout, defaults to 5s
+func WithDialTimeout(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, dialTimeoutKey{}, d)
+	}
+}
+
+// WithConfig sets etcd-specific options, the other options are applied on top of it
+func WithConfig(c clientv3.Config) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, configKey{}, c)
+	}
+}
@@ -0,0 +1,64 @@
+package etcd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nextpkg/nextcfg/encoder"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// makeMap maps the keys to a tree split on "/", the values are decoded as maps or arrays like consul does
+func makeMap(e encoder.Encoder, kvs []*mvccpb.KeyValue, stripPrefix string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	// the parents go before their children
+	sort.Slice(kvs, func(i, j int) bool {
+		return string(kvs[i].Key) < string(kvs[j].Key)
+	})
+
+	for _, kv := range kvs {
+		pathString := strings.Trim(strings.TrimPrefix(string(kv.Key), stripPrefix), "/")
+		if pathString == "" || len(kv.Value) == 0 {
+			continue
+		}
+
+		var val interface{}
+		arrayV, mapV := []interface{}{}, map[string]interface{}{}
+		switch {
+		case e.Decode(kv.Value, &arrayV) == nil:
+			val = arrayV
+		case e.Decode(kv.Value, &mapV) == nil:
+			val = mapV
+		default:
+			return nil, fmt.Errorf("faild decode value. path: %s", pathString)
+		}
+
+		// find (or create) the leaf node we want to put this value at
+		target := data
+		path := strings.Split(pathString, "/")
+		for _, dir := range path[:len(path)-1] {
+			next, ok := target[dir].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[dir] = next
+			}
+			target = next
+		}
+
+		leafDir := path[len(path)-1]
+		if m, ok := val.(map[string]interface{}); ok {
+			// the keys of the children are kept
+			if leaf, ok := target[leafDir].(map[string]interface{}); ok {
+				for k, v := range m {
+					leaf[k] = v
+				}
+				continue
+			}
+		}
+		target[leafDir] = val
+	}
+
+	return data, nil
+}
@@ -0,0 +1,168 @@
+package etcd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// retryInterval is the pause before the watch is resumed after a disconnect
+var retryInterval = time.Second
+
+type watcher struct {
+	e *etcd
+	// the keys under the prefix as of rev
+	kvs map[string]*mvccpb.KeyValue
+	rev int64
+	// whether the keys changed after the last read
+	pending bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	ch     chan *source.ChangeSet
+	exit   chan bool
+	once   sync.Once
+}
+
+func newWatcher(e *etcd) (source.Watcher, error) {
+	// a member cut off from the quorum closes the watch instead of going silent
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(context.Background()))
+
+	w := &watcher{
+		e:      e,
+		ctx:    ctx,
+		cancel: cancel,
+		ch:     make(chan *source.ChangeSet),
+		exit:   make(chan bool),
+	}
+
+	if err := w.sync(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the keys under the prefix may have changed after the last Read
+	if last := e.rev.Load(); last > 0 && w.rev > last {
+		w.pending = true
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// sync reloads the current keys
+func (w *watcher) sync() error {
+	ctx, cancel := context.WithTimeout(w.ctx, DefaultDialTimeout)
+	defer cancel()
+
+	rsp, err := w.e.client.Get(ctx, w.e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+
+	w.kvs = make(map[string]*mvccpb.KeyValue, len(rsp.Kvs))
+	for _, kv := range rsp.Kvs {
+		w.kvs[string(kv.Key)] = kv
+	}
+	w.rev = rsp.Header.Revision
+	return nil
+}
+
+func (w *watcher) run() {
+	if w.pending {
+		w.send()
+	}
+
+	for {
+		compacted := false
+
+		// resume from the revision after the last seen one, so no event is missed across disconnects
+		wch := w.e.client.Watch(w.ctx, w.e.prefix,
+			clientv3.WithPrefix(), clientv3.WithRev(w.rev+1), clientv3.WithProgressNotify())
+
+		for rsp := range wch {
+			if rsp.CompactRevision > 0 {
+				compacted = true
+				break
+			}
+			if err := rsp.Err(); err != nil {
+				slog.Warn("etcd watch failed.", slog.String("source", w.e.String()), slog.String("err", err.Error()))
+				break
+			}
+
+			w.rev = rsp.Header.Revision
+			if len(rsp.Events) == 0 {
+				continue
+			}
+
+			for _, ev := range rsp.Events {
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					w.kvs[string(ev.Kv.Key)] = ev.Kv
+				case clientv3.EventTypeDelete:
+					delete(w.kvs, string(ev.Kv.Key))
+				}
+			}
+			w.send()
+		}
+
+		select {
+		case <-w.exit:
+			return
+		case <-time.After(retryInterval):
+		}
+
+		// the revisions to resume from are gone, start over from the current keys
+		if compacted {
+			if err := w.sync(); err != nil {
+				slog.Warn("etcd sync failed.", slog.String("source", w.e.String()), slog.String("err", err.Error()))
+				continue
+			}
+			w.send()
+		}
+	}
+}
+
+// send encodes the current keys and hands the change set to Next
+func (w *watcher) send() {
+	kvs := make([]*mvccpb.KeyValue, 0, len(w.kvs))
+	for _, kv := range w.kvs {
+		kvs = append(kvs, kv)
+	}
+
+	cs, err := w.e.changeSet(kvs)
+	if err != nil {
+		slog.Warn("etcd change set dropped.", slog.String("source", w.e.String()), slog.String("err", err.Error()))
+		return
+	}
+
+	select {
+	case w.ch <- cs:
+	case <-w.exit:
+	}
+}
+
+// Next ...
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.ch:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop ...
+func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		close(w.exit)
+		w.cancel()
+	})
+	return nil
+}
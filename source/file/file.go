@@ -8,17 +8,25 @@ import (
 	"os"
 
 	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
 	"log"
+	"sync/atomic"
+	"time"
 )
 
 type file struct {
-	path string
-	opts source.Options
+	path     string
+	debounce time.Duration
+	opts     source.Options
+	// the checksum of the last read
+	sum atomic.Value
 }
 
 var (
 	// DefaultPath 默认文件名
 	DefaultPath = "config.json"
+	// DefaultDebounce is how long the bursts of events are coalesced
+	DefaultDebounce = 100 * time.Millisecond
 )
 
 const sourceName = "file"
@@ -26,6 +34,9 @@ const sourceName = "file"
 func init() {
 	registry.SetCfgSource(sourceName)
 	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
+		if profile := registry.GetProfile(); profile != "" {
+			return GetProfileLoader(target, profile)
+		}
 		return GetLoader(target)
 	})
 }
@@ -57,20 +68,42 @@ func (f *file) Read() (*source.ChangeSet, error) {
 	cs := &source.ChangeSet{
 		Format:    format(f.path, f.opts.Encoder),
 		Source:    f.String(),
+		Algorithm: f.opts.Checksum,
 		Timestamp: info.ModTime(),
 		Data:      b,
 	}
-	cs.Checksum = cs.Sum()
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
+
+	if f.opts.Integrity {
+		if err = f.verify(cs); err != nil {
+			return nil, err
+		}
+	}
+	f.sum.Store(cs.Checksum)
 
 	return cs, nil
 }
 
+// verify checks the change set against the sidecar file of the checksum, e.g. config.json.sha256
+func (f *file) verify(cs *source.ChangeSet) error {
+	b, err := os.ReadFile(f.path + "." + cs.Algorithm)
+	if err != nil {
+		return errors.Wrap(err, "read checksum file failed")
+	}
+	return cs.Verify(string(b))
+}
+
 // String file
 func (f *file) String() string {
-	return sourceName
+	if f.opts.Name != "" {
+		return f.opts.Name
+	}
+	return sourceName + ":" + f.path
 }
 
-// Watch ...
+// Watch watches the directory of the file, so the atomic renames and symlink swaps are seen too
 func (f *file) Watch() (source.Watcher, error) {
 	if _, err := os.Stat(f.path); err != nil {
 		return nil, err
@@ -91,7 +124,11 @@ func NewSource(opts ...source.Option) source.Source {
 	if ok {
 		path = f
 	}
-	return &file{opts: options, path: path}
+	debounce := DefaultDebounce
+	if d, ok := options.Context.Value(debounceKey{}).(time.Duration); ok && d > 0 {
+		debounce = d
+	}
+	return &file{opts: options, path: path, debounce: debounce}
 }
 
 // GetLoader sets file source
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/source"
 	"github.com/nextpkg/nextcfg/source/file"
 	"github.com/stretchr/testify/require"
 )
@@ -62,3 +63,220 @@ func TestFile(t *testing.T) {
 
 	at.Equal(data, c.Data)
 }
+
+func TestIntegrity(t *testing.T) {
+	at := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := []byte(`{"foo": "bar"}`)
+	at.Nil(os.WriteFile(path, data, 0o600))
+
+	f := file.NewSource(file.WithPath(path), source.WithIntegrity())
+	_, err := f.Read()
+	at.NotNil(err)
+
+	// sha256sum config.json > config.json.sha256
+	digest, err := source.Checksum(source.SHA256, data)
+	at.Nil(err)
+	at.Nil(os.WriteFile(path+".sha256", []byte(digest+"  config.json\n"), 0o600))
+
+	c, err := f.Read()
+	at.Nil(err)
+	at.Equal(digest, c.Checksum)
+
+	at.Nil(os.WriteFile(path, []byte(`{"foo": "baz"}`), 0o600))
+	_, err = f.Read()
+	at.ErrorIs(err, source.ErrChecksumMismatch)
+}
+
+func TestProfile(t *testing.T) {
+	at := require.New(t)
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.json")
+
+	at.Equal([]string{
+		base,
+		filepath.Join(dir, "config.staging.json"),
+		filepath.Join(dir, "config.local.json"),
+	}, file.ProfilePaths(base, "staging"))
+
+	at.Nil(os.WriteFile(base, []byte(`{"name": "base", "db": {"host": "localhost", "port": 3306}}`), 0o600))
+	at.Nil(os.WriteFile(filepath.Join(dir, "config.local.json"), []byte(`{"db": {"port": 3307}}`), 0o600))
+	at.Nil(os.WriteFile(filepath.Join(dir, "config.staging.json"), []byte(`{"name": "staging", "db": {"host": "mysql"}}`), 0o600))
+
+	// the local file is loaded before the profile file, but layered above it
+	at.Len(file.ProfileSources(base, "prod"), 2)
+	sources := file.ProfileSources(base, "staging")
+	at.Len(sources, 3)
+
+	conf, err := nextcfg.NewConfig()
+	at.Nil(err)
+	at.Nil(conf.Load(sources[2], sources[0], sources[1]))
+
+	at.Equal("staging", conf.Get("name").String(""))
+	at.Equal("mysql", conf.Get("db", "host").String(""))
+	at.Equal(3307, conf.Get("db", "port").Int(0))
+
+	// the sources are named after their layer
+	at.Equal("profile", conf.Explain("db", "host")["db.host"].Source)
+	at.Equal("local", conf.Sources()[0].Source)
+	at.Nil(conf.Unload("local"))
+	at.Equal(3306, conf.Get("db", "port").Int(0))
+	at.Nil(conf.Close())
+}
+
+func TestWatch(t *testing.T) {
+	at := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	at.Nil(os.WriteFile(path, []byte(`{"v": 1}`), 0o600))
+
+	src := file.NewSource(file.WithPath(path), file.WithDebounce(50*time.Millisecond))
+	_, err := src.Read()
+	at.Nil(err)
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	ch := make(chan *source.ChangeSet)
+	go func() {
+		for {
+			cs, err := w.Next()
+			if err != nil {
+				close(ch)
+				return
+			}
+			ch <- cs
+		}
+	}()
+
+	next := func() *source.ChangeSet {
+		select {
+		case cs := <-ch:
+			return cs
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch timeout")
+		}
+		return nil
+	}
+	quiet := func() {
+		select {
+		case cs := <-ch:
+			t.Fatalf("unexpected change set: %s", cs.Data)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	// in place, a burst of writes is a single reload
+	for i := 2; i <= 5; i++ {
+		at.Nil(os.WriteFile(path, []byte(fmt.Sprintf(`{"v": %d}`, i)), 0o600))
+	}
+	at.JSONEq(`{"v": 5}`, string(next().Data))
+	quiet()
+
+	// touching changes nothing
+	now := time.Now()
+	at.Nil(os.Chtimes(path, now, now))
+	at.Nil(os.WriteFile(path, []byte(`{"v": 5}`), 0o600))
+	quiet()
+
+	// an editor writing a temp file and renaming it over the file
+	tmp := filepath.Join(dir, ".config.json.swp")
+	at.Nil(os.WriteFile(tmp, []byte(`{"v": 6}`), 0o600))
+	at.Nil(os.Rename(tmp, path))
+	at.JSONEq(`{"v": 6}`, string(next().Data))
+
+	// and once more, the watch outlives the replaced file
+	at.Nil(os.WriteFile(tmp, []byte(`{"v": 7}`), 0o600))
+	at.Nil(os.Rename(tmp, path))
+	at.JSONEq(`{"v": 7}`, string(next().Data))
+	quiet()
+}
+
+func TestWatchSymlinkSwap(t *testing.T) {
+	at := require.New(t)
+
+	// a kubernetes configmap volume: config.json -> ..data/config.json, ..data -> ..v1
+	dir := t.TempDir()
+	at.Nil(os.Mkdir(filepath.Join(dir, "..v1"), 0o755))
+	at.Nil(os.WriteFile(filepath.Join(dir, "..v1", "config.json"), []byte(`{"v": 1}`), 0o600))
+	at.Nil(os.Symlink("..v1", filepath.Join(dir, "..data")))
+	at.Nil(os.Symlink(filepath.Join("..data", "config.json"), filepath.Join(dir, "config.json")))
+
+	src := file.NewSource(file.WithPath(filepath.Join(dir, "config.json")), file.WithDebounce(50*time.Millisecond))
+	cs, err := src.Read()
+	at.Nil(err)
+	at.JSONEq(`{"v": 1}`, string(cs.Data))
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	ch := make(chan *source.ChangeSet, 2)
+	go func() {
+		for {
+			cs, err := w.Next()
+			if err != nil {
+				return
+			}
+			ch <- cs
+		}
+	}()
+
+	for i, v := range []string{"..v2", "..v3"} {
+		at.Nil(os.Mkdir(filepath.Join(dir, v), 0o755))
+		data := fmt.Sprintf(`{"v": %d}`, i+2)
+		at.Nil(os.WriteFile(filepath.Join(dir, v, "config.json"), []byte(data), 0o600))
+		at.Nil(os.Symlink(v, filepath.Join(dir, "..data_tmp")))
+		at.Nil(os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+		select {
+		case cs := <-ch:
+			at.JSONEq(data, string(cs.Data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch timeout")
+		}
+	}
+
+	select {
+	case cs := <-ch:
+		t.Fatalf("unexpected change set: %s", cs.Data)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchSymlinkTarget(t *testing.T) {
+	at := require.New(t)
+
+	// the file links to a file of another directory, which is edited in place
+	dir, other := t.TempDir(), t.TempDir()
+	target := filepath.Join(other, "app.json")
+	at.Nil(os.WriteFile(target, []byte(`{"v": 1}`), 0o600))
+	at.Nil(os.Symlink(target, filepath.Join(dir, "config.json")))
+
+	src := file.NewSource(file.WithPath(filepath.Join(dir, "config.json")), file.WithDebounce(50*time.Millisecond))
+	_, err := src.Read()
+	at.Nil(err)
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	at.Nil(os.WriteFile(target, []byte(`{"v": 2}`), 0o600))
+
+	ch := make(chan *source.ChangeSet, 1)
+	go func() {
+		cs, _ := w.Next()
+		ch <- cs
+	}()
+
+	select {
+	case cs := <-ch:
+		at.JSONEq(`{"v": 2}`, string(cs.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch timeout")
+	}
+}
@@ -1,15 +1,10 @@
 package file
 
 import (
-	"strings"
-
 	"github.com/nextpkg/nextcfg/encoder"
+	formats "github.com/nextpkg/nextcfg/source/file/format"
 )
 
 func format(p string, e encoder.Encoder) string {
-	parts := strings.Split(p, ".")
-	if len(parts) > 1 {
-		return parts[len(parts)-1]
-	}
-	return e.String()
+	return formats.Detect(p, e)
 }
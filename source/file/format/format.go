@@ -0,0 +1,80 @@
+// Package format detects, decodes and merges the config files by format,
+// for the sources which read several files, e.g. dir and git
+package format
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nextpkg/nextcfg/encoder"
+	"github.com/nextpkg/nextcfg/encoder/hcl"
+	"github.com/nextpkg/nextcfg/encoder/json"
+	"github.com/nextpkg/nextcfg/encoder/toml"
+	"github.com/nextpkg/nextcfg/encoder/xml"
+	"github.com/nextpkg/nextcfg/encoder/yaml"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
+)
+
+// Encoders decode the files by format
+var Encoders = map[string]encoder.Encoder{
+	"json": json.NewEncoder(),
+	"yaml": yaml.NewEncoder(),
+	"yml":  yaml.NewEncoder(),
+	"toml": toml.NewEncoder(),
+	"hcl":  hcl.NewEncoder(),
+	"xml":  xml.NewEncoder(),
+}
+
+// Detect returns the format of the file by its extension, the one of e if it has none.
+// The patches of the earlier sources are told by their double extension, e.g. overlay.merge-patch.json.
+func Detect(p string, e encoder.Encoder) string {
+	for _, f := range []string{source.FormatMergePatch, source.FormatJSONPatch} {
+		if strings.HasSuffix(p, "."+f+".json") {
+			return f
+		}
+	}
+
+	parts := strings.Split(path.Base(p), ".")
+	if len(parts) > 1 {
+		return parts[len(parts)-1]
+	}
+	return e.String()
+}
+
+// Supported reports whether the file can be decoded by its extension, the patches can't
+func Supported(p string) bool {
+	if !strings.Contains(path.Base(p), ".") {
+		return false
+	}
+	_, ok := Encoders[Detect(p, nil)]
+	return ok
+}
+
+// Decode decodes the data of the file by its format
+func Decode(p string, data []byte, e encoder.Encoder) (map[string]interface{}, error) {
+	enc, ok := Encoders[Detect(p, e)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format of %s", p)
+	}
+
+	var v map[string]interface{}
+	if err := enc.Decode(data, &v); err != nil {
+		return nil, errors.Wrapf(err, "decode %s failed", p)
+	}
+	return v, nil
+}
+
+// Merge deep merges src into dst, the maps are merged and the rest is replaced
+func Merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		dm, ok2 := dst[k].(map[string]interface{})
+		if ok && ok2 {
+			Merge(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
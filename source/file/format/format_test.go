@@ -0,0 +1,34 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/nextpkg/nextcfg/encoder/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMerge(t *testing.T) {
+	at := assert.New(t)
+
+	at.True(Supported("conf.d/10-base.yaml"))
+	at.False(Supported("overlay.merge-patch.json"))
+	at.False(Supported("conf.d/config"))
+	at.False(Supported("README"))
+
+	merged := make(map[string]interface{})
+	for _, f := range []struct{ p, data string }{
+		{"base.json", `{"db": {"host": "a", "port": 1}, "hosts": ["a"]}`},
+		{"local.yaml", "db:\n  host: b\nhosts: [b]\n"},
+		{"local.toml", "[db]\nport = 2\n"},
+	} {
+		v, err := Decode(f.p, []byte(f.data), json.NewEncoder())
+		at.Nil(err)
+		Merge(merged, v)
+	}
+	at.Equal(map[string]interface{}{"db": map[string]interface{}{"host": "b", "port": int64(2)}, "hosts": []interface{}{"b"}}, merged)
+
+	_, err := Decode("overlay.merge-patch.json", []byte(`{}`), json.NewEncoder())
+	at.NotNil(err)
+	_, err = Decode("base.json", []byte(`{`), json.NewEncoder())
+	at.NotNil(err)
+}
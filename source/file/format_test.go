@@ -19,6 +19,8 @@ func TestFormat(t *testing.T) {
 		{"/foo/bar.xml", "xml"},
 		{"/foo/bar.conf.ini", "ini"},
 		{"conf", e.String()},
+		{"/foo/overlay.merge-patch.json", source.FormatMergePatch},
+		{"/foo/overlay.json-patch.json", source.FormatJSONPatch},
 	}
 
 	for _, d := range testCases {
@@ -2,11 +2,13 @@ package file
 
 import (
 	"context"
+	"time"
 
 	"github.com/nextpkg/nextcfg/source"
 )
 
 type filePathKey struct{}
+type debounceKey struct{}
 
 // WithPath sets the path to file
 func WithPath(p string) source.Option {
@@ -17,3 +19,13 @@ func WithPath(p string) source.Option {
 		o.Context = context.WithValue(o.Context, filePathKey{}, p)
 	}
 }
+
+// WithDebounce sets how long the bursts of events are coalesced before reloading, defaults to 100ms
+func WithDebounce(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, debounceKey{}, d)
+	}
+}
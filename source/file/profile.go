@@ -0,0 +1,69 @@
+package file
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/loader"
+	"github.com/nextpkg/nextcfg/source"
+)
+
+// DefaultProfilePath 启用profile时默认的基础配置文件
+var DefaultProfilePath = "config.yaml"
+
+// ProfilePaths returns the base file, the file of the profile and the local file,
+// e.g. config.yaml, config.staging.yaml and config.local.yaml
+func ProfilePaths(base, profile string) []string {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	paths := []string{base}
+	if profile != "" {
+		paths = append(paths, stem+"."+profile+ext)
+	}
+	return append(paths, stem+".local"+ext)
+}
+
+// ProfileSources returns the file sources of ProfilePaths in the file, profile and local layers.
+// The base file is required, the others are skipped if they don't exist.
+// The sources are named after their layer unless opts name them.
+func ProfileSources(base, profile string, opts ...source.Option) []source.Source {
+	paths := ProfilePaths(base, profile)
+	layers := []loader.Layer{loader.LayerFile, loader.LayerProfile, loader.LayerLocal}
+	if profile == "" {
+		layers = []loader.Layer{loader.LayerFile, loader.LayerLocal}
+	}
+
+	sources := make([]source.Source, 0, len(paths))
+	for i, path := range paths {
+		if i > 0 {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+		}
+		named := append([]source.Option{source.WithName(layers[i].String())}, opts...)
+		src := NewSource(append(named, WithPath(path))...)
+		sources = append(sources, loader.InLayer(layers[i], src))
+	}
+	return sources
+}
+
+// GetProfileLoader loads the base file with the files of the profile, DefaultProfilePath if base is empty
+func GetProfileLoader(base, profile string) nextcfg.Loader {
+	if base == "" {
+		base = DefaultProfilePath
+	}
+
+	return func(l *nextcfg.Loaders) {
+		log.Println("load profile:", profile, "base:", base)
+
+		if err := l.GetCfg().Load(ProfileSources(base, profile)...); err != nil {
+			log.Println(err)
+		} else {
+			l.GetCfg().SetState(true)
+		}
+	}
+}
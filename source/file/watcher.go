@@ -1,68 +1,160 @@
-//go:build !linux
-// +build !linux
-
 package file
 
 import (
-	"os"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/nextpkg/nextcfg/source"
-	"log"
 )
 
+// watcher watches the directory of the file rather than the file itself.
+// A watch on the file is lost when the file is replaced, e.g. an editor writing a temp file and renaming it
+// over the file, or the ..data symlink swap of the kubernetes ConfigMap and Secret volumes.
 type watcher struct {
-	f    *file
+	f *file
+	// the absolute path and the path the symlinks resolve to
+	path   string
+	target string
+	// the directory of the target if it's out of the directory of the file
+	targetDir string
+	last      string
+
 	fw   *fsnotify.Watcher
+	ch   chan *source.ChangeSet
 	exit chan bool
 }
 
 func newWatcher(f *file) (source.Watcher, error) {
+	path, err := filepath.Abs(f.path)
+	if err != nil {
+		return nil, err
+	}
+
 	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := fw.Add(f.path); err != nil {
-		log.Println("add notify path failed:", err)
+	if err = fw.Add(filepath.Dir(path)); err != nil {
+		_ = fw.Close()
+		return nil, err
 	}
 
-	return &watcher{
+	w := &watcher{
 		f:    f,
+		path: path,
 		fw:   fw,
+		ch:   make(chan *source.ChangeSet),
 		exit: make(chan bool),
-	}, nil
+	}
+	w.resolve()
+
+	// the change set the config is loaded from, a burst of events which changes nothing isn't sent
+	w.last, _ = f.sum.Load().(string)
+
+	go w.run()
+
+	return w, nil
 }
 
-// Next ...
-func (w *watcher) Next() (*source.ChangeSet, error) {
-	// is it closed?
-	select {
-	case <-w.exit:
-		return nil, source.ErrWatcherStopped
-	default:
+// resolve follows the symlinks of the file, and watches the directory of the target
+// if the file links out of its directory
+func (w *watcher) resolve() {
+	target, err := filepath.EvalSymlinks(w.path)
+	if err != nil {
+		// removed or dangling in the middle of a swap, the next event resolves it again
+		return
 	}
+	w.target = target
 
-	// try get the event
-	select {
-	case event, _ := <-w.fw.Events:
-		if event.Op == fsnotify.Rename {
-			// check existence of file, and add watch again
-			_, err := os.Stat(event.Name)
-			if err == nil || os.IsExist(err) {
-				if err := w.fw.Add(event.Name); err != nil {
-					log.Println("add notify path failed:", err)
-				}
+	dir := filepath.Dir(target)
+	if dir == w.targetDir {
+		return
+	}
+	if w.targetDir != "" {
+		// the old directory may be gone already
+		_ = w.fw.Remove(w.targetDir)
+		w.targetDir = ""
+	}
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(w.path)); err == nil && parent == dir {
+		return
+	}
+	if err = w.fw.Add(dir); err != nil {
+		log.Println("add notify path failed:", err)
+		return
+	}
+	w.targetDir = dir
+}
+
+// relevant tells whether the event may change the file.
+// The entries starting with .. are the ones the kubernetes volumes swap, e.g. ..data and ..2024_01_01_00_00_00.
+func (w *watcher) relevant(ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+
+	name := filepath.Clean(ev.Name)
+	switch {
+	case name == w.path, name == w.target:
+		return true
+	case w.f.opts.Integrity && (name == w.path+"."+w.f.opts.Checksum || name == w.target+"."+w.f.opts.Checksum):
+		return true
+	}
+	return strings.HasPrefix(filepath.Base(name), "..")
+}
+
+func (w *watcher) run() {
+	// the events are coalesced until the file is quiet for a while,
+	// e.g. the write, rename and remove of an editor saving the file
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-w.exit:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
 			}
-		}
+			if w.relevant(ev) {
+				timer = time.After(w.f.debounce)
+			}
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			log.Println("file watch failed:", err)
+		case <-timer:
+			timer = nil
+
+			w.resolve()
+			cs, err := w.f.Read()
+			if err != nil {
+				log.Println("file read failed:", err)
+				continue
+			}
+			if cs.Checksum == w.last {
+				continue
+			}
+			w.last = cs.Checksum
 
-		c, err := w.f.Read()
-		if err != nil {
-			return nil, err
+			select {
+			case w.ch <- cs:
+			case <-w.exit:
+				return
+			}
 		}
-		return c, nil
-	case err := <-w.fw.Errors:
-		return nil, err
+	}
+}
+
+// Next ...
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.ch:
+		return cs, nil
 	case <-w.exit:
 		return nil, source.ErrWatcherStopped
 	}
@@ -70,5 +162,11 @@ func (w *watcher) Next() (*source.ChangeSet, error) {
 
 // Stop ...
 func (w *watcher) Stop() error {
+	select {
+	case <-w.exit:
+		return nil
+	default:
+		close(w.exit)
+	}
 	return w.fw.Close()
 }
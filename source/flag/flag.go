@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/registry"
 	"log"
 	"strings"
 	"time"
@@ -19,8 +20,8 @@ type flagSrc struct {
 const sourceName = "flag"
 
 func init() {
-	registry.SetDefaultSource(sourceName)
-	registry.SetToHub(sourceName, func(target string) nextcfg.Loader {
+	registry.SetCfgSource(sourceName)
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
 		return GetLoader()
 	})
 }
@@ -73,8 +74,11 @@ func (fs *flagSrc) Read() (*source.ChangeSet, error) {
 		Data:      b,
 		Timestamp: time.Now(),
 		Source:    fs.String(),
+		Algorithm: fs.opts.Checksum,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
 	}
-	cs.Checksum = cs.Sum()
 
 	return cs, nil
 }
@@ -102,6 +106,9 @@ func (fs *flagSrc) Write(*source.ChangeSet) error {
 
 // String flag
 func (fs *flagSrc) String() string {
+	if fs.opts.Name != "" {
+		return fs.opts.Name
+	}
 	return sourceName
 }
 
@@ -0,0 +1,265 @@
+// Package git reads the config files of a branch, a tag or a commit of a git repository
+package git
+
+import (
+	"fmt"
+	"log"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/cmd"
+	"github.com/nextpkg/nextcfg/registry"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/nextpkg/nextcfg/source/file/format"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// sourceName 数据源名称
+const sourceName = "git"
+
+// Algorithm marks the checksums of the change sets as commit hashes
+const Algorithm = "git"
+
+// Predefined variables
+var (
+	DefaultURL      = ""
+	DefaultRef      = ""
+	DefaultFile     = "config.json"
+	DefaultInterval = 30 * time.Second
+)
+
+func init() {
+	registry.SetCfgSource(sourceName)
+
+	// 此处依赖于registry的初始化参数--cfg
+	cmd.AddSubFlags(registry.CfgFlag, sourceName, func() *cmd.FlagSet {
+		fs := cmd.NewFlagSet("--cfg=git", pflag.ContinueOnError)
+		fs.StringVar(&DefaultURL, "config_url", DefaultURL, "git repository url")
+		fs.StringVar(&DefaultRef, "config_ref", DefaultRef, "git branch, tag or commit")
+		fs.DurationVar(&DefaultInterval, "config_interval", DefaultInterval, "git fetch interval")
+		return fs
+	})
+
+	// 注册，target是逗号分隔的文件路径
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
+		opts := []source.Option{WithURL(DefaultURL), WithRef(DefaultRef), WithInterval(DefaultInterval)}
+		for _, f := range strings.Split(target, ",") {
+			if f != "" {
+				opts = append(opts, WithFile(f))
+			}
+		}
+		return GetLoader(opts...)
+	})
+}
+
+type gitSource struct {
+	url      string
+	ref      string
+	files    []string
+	auth     transport.AuthMethod
+	interval time.Duration
+	opts     source.Options
+
+	// guards the repository, which is cloned at the first read
+	sync.Mutex
+	repo *gogit.Repository
+	// the commit of the last read
+	head string
+}
+
+// Read fetches the repository and reads the files of the ref
+func (g *gitSource) Read() (*source.ChangeSet, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	commit, err := g.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := g.read(commit)
+	if err != nil {
+		return nil, err
+	}
+	g.head = cs.Checksum
+	return cs, nil
+}
+
+// fetch clones or fetches the repository and returns the commit of the ref, callers hold the lock
+func (g *gitSource) fetch() (*object.Commit, error) {
+	if g.repo == nil {
+		// no worktree, the files are read from the objects in memory
+		repo, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{
+			URL:  g.url,
+			Auth: g.auth,
+			Tags: gogit.AllTags,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "clone %s failed", g.url)
+		}
+		g.repo = repo
+
+		// the default branch of the remote
+		if g.ref == "" {
+			head, err := repo.Head()
+			if err != nil {
+				return nil, err
+			}
+			g.ref = head.Name().Short()
+		}
+	} else {
+		err := g.repo.Fetch(&gogit.FetchOptions{
+			Auth:  g.auth,
+			Tags:  gogit.AllTags,
+			Force: true,
+			RefSpecs: []config.RefSpec{
+				"+refs/heads/*:refs/remotes/origin/*",
+				"+refs/tags/*:refs/tags/*",
+			},
+		})
+		if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return nil, errors.Wrapf(err, "fetch %s failed", g.url)
+		}
+	}
+
+	// a branch moves with the remote, a tag or a commit stays put
+	for _, rev := range []string{"refs/remotes/origin/" + g.ref, "refs/tags/" + g.ref, g.ref} {
+		h, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			continue
+		}
+		return g.repo.CommitObject(*h)
+	}
+	return nil, fmt.Errorf("unknown ref %q of %s", g.ref, g.url)
+}
+
+// read the files of the commit, the commit hash is the checksum
+func (g *gitSource) read(commit *object.Commit) (*source.ChangeSet, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &source.ChangeSet{
+		Source:    g.String(),
+		Algorithm: Algorithm,
+		Checksum:  commit.Hash.String(),
+		Timestamp: commit.Committer.When,
+	}
+
+	if len(g.files) == 1 {
+		f, err := tree.File(g.files[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s at %s", g.files[0], commit.Hash)
+		}
+		data, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		cs.Data = []byte(data)
+		cs.Format = format.Detect(g.files[0], g.opts.Encoder)
+		return cs, nil
+	}
+
+	// several files are decoded by their format and merged in order
+	merged := make(map[string]interface{})
+	for _, path := range g.files {
+		f, err := tree.File(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s at %s", path, commit.Hash)
+		}
+		data, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := format.Decode(path, []byte(data), g.opts.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		format.Merge(merged, v)
+	}
+
+	cs.Data, err = g.opts.Encoder.Encode(merged)
+	if err != nil {
+		return nil, err
+	}
+	cs.Format = g.opts.Encoder.String()
+	return cs, nil
+}
+
+// Write is unsupported
+func (g *gitSource) Write(*source.ChangeSet) error {
+	return nil
+}
+
+// String git
+func (g *gitSource) String() string {
+	if g.opts.Name != "" {
+		return g.opts.Name
+	}
+	// the password of the url is masked
+	if v, err := neturl.Parse(g.url); err == nil {
+		return sourceName + ":" + v.Redacted()
+	}
+	return sourceName + ":" + g.url
+}
+
+// Watch fetches the repository periodically, a change set is sent for every new commit of the ref
+func (g *gitSource) Watch() (source.Watcher, error) {
+	return newWatcher(g)
+}
+
+// NewSource creates a new git source
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	g := &gitSource{
+		url:      DefaultURL,
+		ref:      DefaultRef,
+		files:    []string{DefaultFile},
+		interval: DefaultInterval,
+		opts:     options,
+	}
+
+	if u, ok := options.Context.Value(urlKey{}).(string); ok && u != "" {
+		g.url = u
+	}
+	if r, ok := options.Context.Value(refKey{}).(string); ok && r != "" {
+		g.ref = r
+	}
+	if f, ok := options.Context.Value(filesKey{}).([]string); ok && len(f) > 0 {
+		g.files = f
+	}
+	if a, ok := options.Context.Value(authKey{}).(transport.AuthMethod); ok {
+		g.auth = a
+	}
+	if d, ok := options.Context.Value(intervalKey{}).(time.Duration); ok && d > 0 {
+		g.interval = d
+	}
+
+	return g
+}
+
+// GetLoader sets git source
+func GetLoader(opts ...source.Option) nextcfg.Loader {
+	return func(l *nextcfg.Loaders) {
+		err := l.GetCfg().Load(NewSource(opts...))
+		if err != nil {
+			log.Println(err)
+		} else {
+			l.GetCfg().SetState(true)
+		}
+	}
+}
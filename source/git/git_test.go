@@ -0,0 +1,128 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/require"
+)
+
+// repo is a local repository to commit the config files to
+type repo struct {
+	t   *testing.T
+	dir string
+	r   *gogit.Repository
+}
+
+func newRepo(t *testing.T) *repo {
+	dir := t.TempDir()
+	r, err := gogit.PlainInit(dir, false)
+	require.Nil(t, err)
+	return &repo{t: t, dir: dir, r: r}
+}
+
+// commit writes the files and returns the commit hash
+func (r *repo) commit(files map[string]string) string {
+	wt, err := r.r.Worktree()
+	require.Nil(r.t, err)
+
+	for name, data := range files {
+		require.Nil(r.t, os.WriteFile(filepath.Join(r.dir, name), []byte(data), 0o644))
+		_, err = wt.Add(name)
+		require.Nil(r.t, err)
+	}
+
+	h, err := wt.Commit("update config", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "ci", Email: "ci@example.com", When: time.Now()},
+	})
+	require.Nil(r.t, err)
+	return h.String()
+}
+
+func TestGit(t *testing.T) {
+	at := require.New(t)
+
+	r := newRepo(t)
+	first := r.commit(map[string]string{
+		"config.yaml": "db:\n  host: localhost\n  port: 3306\n",
+		"local.json":  `{"db": {"host": "10.0.0.1"}}`,
+	})
+
+	// a single file keeps its format
+	cs, err := NewSource(WithURL(r.dir), WithFile("config.yaml")).Read()
+	at.Nil(err)
+	at.Equal("yaml", cs.Format)
+	at.Equal(first, cs.Checksum)
+	at.Equal(Algorithm, cs.Algorithm)
+
+	// several files are merged in order
+	cs, err = NewSource(WithURL(r.dir), WithFile("config.yaml", "local.json")).Read()
+	at.Nil(err)
+	at.Equal("json", cs.Format)
+	at.JSONEq(`{"db": {"host": "10.0.0.1", "port": 3306}}`, string(cs.Data))
+
+	_, err = NewSource(WithURL(r.dir), WithFile("missing.json")).Read()
+	at.NotNil(err)
+}
+
+func TestGitRef(t *testing.T) {
+	at := require.New(t)
+
+	r := newRepo(t)
+	first := r.commit(map[string]string{"config.json": `{"v": 1}`})
+	head, err := r.r.Head()
+	at.Nil(err)
+	_, err = r.r.CreateTag("v1", head.Hash(), nil)
+	at.Nil(err)
+	r.commit(map[string]string{"config.json": `{"v": 2}`})
+
+	for _, ref := range []string{"v1", first} {
+		cs, err := NewSource(WithURL(r.dir), WithRef(ref)).Read()
+		at.Nil(err)
+		at.JSONEq(`{"v": 1}`, string(cs.Data), ref)
+		at.Equal(first, cs.Checksum)
+	}
+
+	cs, err := NewSource(WithURL(r.dir), WithRef(head.Name().Short())).Read()
+	at.Nil(err)
+	at.JSONEq(`{"v": 2}`, string(cs.Data))
+
+	_, err = NewSource(WithURL(r.dir), WithRef("v404")).Read()
+	at.NotNil(err)
+}
+
+func TestGitWatch(t *testing.T) {
+	at := require.New(t)
+
+	r := newRepo(t)
+	r.commit(map[string]string{"config.json": `{"v": 1}`})
+
+	src := NewSource(WithURL(r.dir), WithInterval(50*time.Millisecond))
+	_, err := src.Read()
+	at.Nil(err)
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	second := r.commit(map[string]string{"config.json": `{"v": 2}`})
+
+	ch := make(chan *source.ChangeSet, 1)
+	go func() {
+		cs, _ := w.Next()
+		ch <- cs
+	}()
+
+	select {
+	case cs := <-ch:
+		at.JSONEq(`{"v": 2}`, string(cs.Data))
+		at.Equal(second, cs.Checksum)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch timeout")
+	}
+}
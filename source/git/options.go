@@ -0,0 +1,66 @@
+package git
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type urlKey struct{}
+type refKey struct{}
+type filesKey struct{}
+type authKey struct{}
+type intervalKey struct{}
+
+// WithURL sets the url of the repository, a local path works too
+func WithURL(u string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, urlKey{}, u)
+	}
+}
+
+// WithRef sets the branch, the tag or the commit to read, defaults to the HEAD of the repository
+func WithRef(ref string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, refKey{}, ref)
+	}
+}
+
+// WithFile adds the files to read, they're merged in order. Defaults to config.json
+func WithFile(paths ...string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		prev, _ := o.Context.Value(filesKey{}).([]string)
+		o.Context = context.WithValue(o.Context, filesKey{}, append(prev[:len(prev):len(prev)], paths...))
+	}
+}
+
+// WithAuth sets the auth of a remote repository, e.g. http.BasicAuth or ssh.PublicKeys of go-git
+func WithAuth(a transport.AuthMethod) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, authKey{}, a)
+	}
+}
+
+// WithInterval sets how often new commits are fetched, defaults to 30s
+func WithInterval(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, intervalKey{}, d)
+	}
+}
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type watcher struct {
+	g    *gitSource
+	last string
+	ch   chan *source.ChangeSet
+	exit chan bool
+}
+
+func newWatcher(g *gitSource) (*watcher, error) {
+	w := &watcher{
+		g:    g,
+		ch:   make(chan *source.ChangeSet),
+		exit: make(chan bool),
+	}
+
+	// the commits after the one the config is loaded from are sent
+	g.Lock()
+	w.last = g.head
+	g.Unlock()
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case <-w.exit:
+			return
+		case <-time.After(w.g.interval):
+		}
+
+		w.g.Lock()
+		commit, err := w.g.fetch()
+		if err == nil && commit.Hash.String() == w.last {
+			w.g.Unlock()
+			continue
+		}
+
+		var cs *source.ChangeSet
+		if err == nil {
+			cs, err = w.g.read(commit)
+		}
+		w.g.Unlock()
+
+		if err != nil {
+			slog.Warn("git watch failed.", slog.String("source", w.g.String()), slog.String("err", err.Error()))
+			continue
+		}
+		w.last = cs.Checksum
+
+		select {
+		case w.ch <- cs:
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+// Next 处理新配置
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.ch:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop 关闭监听器
+func (w *watcher) Stop() error {
+	select {
+	case <-w.exit:
+	default:
+		close(w.exit)
+	}
+
+	return nil
+}
@@ -15,6 +15,8 @@ import (
 
 type memory struct {
 	sync.RWMutex
+	name      string
+	checksum  string
 	ChangeSet *source.ChangeSet
 	Watchers  map[string]*watcher
 }
@@ -42,6 +44,7 @@ func (s *memory) Read() (*source.ChangeSet, error) {
 		Timestamp: s.ChangeSet.Timestamp,
 		Data:      s.ChangeSet.Data,
 		Checksum:  s.ChangeSet.Checksum,
+		Algorithm: s.ChangeSet.Algorithm,
 		Source:    s.ChangeSet.Source,
 	}
 	return cs, nil
@@ -63,27 +66,39 @@ func (s *memory) Watch() (source.Watcher, error) {
 
 // Write ...
 func (s *memory) Write(cs *source.ChangeSet) error {
-	s.Update(cs)
-	return nil
+	return s.update(cs)
 }
 
 // Update allows manual updates of the config data.
 func (s *memory) Update(c *source.ChangeSet) {
+	if err := s.update(c); err != nil {
+		log.Println("memory update failed:", err)
+	}
+}
+
+func (s *memory) update(c *source.ChangeSet) error {
 	// don't process nil
 	if c == nil {
-		return
+		return nil
 	}
 
-	// hash the file
-	s.Lock()
-	// update change set
-	s.ChangeSet = &source.ChangeSet{
+	cs := &source.ChangeSet{
 		Data:      c.Data,
 		Format:    c.Format,
-		Source:    "memory",
+		Source:    s.String(),
+		Algorithm: s.checksum,
 		Timestamp: time.Now(),
 	}
-	s.ChangeSet.Checksum = s.ChangeSet.Sum()
+
+	// hash the file
+	var err error
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return err
+	}
+
+	s.Lock()
+	// update change set
+	s.ChangeSet = cs
 
 	// update watchers
 	for _, w := range s.Watchers {
@@ -93,10 +108,15 @@ func (s *memory) Update(c *source.ChangeSet) {
 		}
 	}
 	s.Unlock()
+
+	return nil
 }
 
 // String memory
 func (s *memory) String() string {
+	if s.name != "" {
+		return s.name
+	}
 	return sourceName
 }
 
@@ -108,6 +128,8 @@ func NewSource(opts ...source.Option) source.Source {
 	}
 
 	s := &memory{
+		name:     options.Name,
+		checksum: options.Checksum,
 		Watchers: make(map[string]*watcher),
 	}
 
@@ -39,3 +39,13 @@ func WithJSON(d []byte) source.Option {
 func WithYAML(d []byte) source.Option {
 	return withData(d, "yaml")
 }
+
+// WithMergePatch allows the source data to be set to a JSON merge patch (RFC 7396)
+func WithMergePatch(d []byte) source.Option {
+	return withData(d, source.FormatMergePatch)
+}
+
+// WithJSONPatch allows the source data to be set to a JSON patch (RFC 6902)
+func WithJSONPatch(d []byte) source.Option {
+	return withData(d, source.FormatJSONPatch)
+}
@@ -12,6 +12,16 @@ type Options struct {
 	// Encoder
 	Encoder encoder.Encoder
 
+	// Name identifies the source in the change sets, defaults to the source type and its target,
+	// e.g. file:/etc/app.yaml, or just the type for the sources without one, e.g. env
+	Name string
+
+	// Checksum algorithm of the change sets
+	Checksum string
+
+	// Integrity requires the data read to match the digest supplied by the source
+	Integrity bool
+
 	// for alternative data
 	Context context.Context
 }
@@ -22,8 +32,9 @@ type Option func(o *Options)
 // NewOptions 新的数据源配置
 func NewOptions(opts ...Option) Options {
 	options := Options{
-		Encoder: json.NewEncoder(),
-		Context: context.Background(),
+		Encoder:  json.NewEncoder(),
+		Checksum: DefaultChecksum,
+		Context:  context.Background(),
 	}
 
 	for _, o := range opts {
@@ -39,3 +50,26 @@ func WithEncoder(e encoder.Encoder) Option {
 		o.Encoder = e
 	}
 }
+
+// WithName sets the unique name of the source, e.g. to tell two env sources apart or to keep the name
+// of a file source when its path changes
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithChecksum sets the checksum algorithm of the change sets, e.g. SHA256 or XXHash
+func WithChecksum(algorithm string) Option {
+	return func(o *Options) {
+		o.Checksum = algorithm
+	}
+}
+
+// WithIntegrity makes Read fail unless the data matches the digest supplied by the source,
+// e.g. a .sha256 sidecar file or a checksum header. It's ignored by the sources without digests.
+func WithIntegrity() Option {
+	return func(o *Options) {
+		o.Integrity = true
+	}
+}
@@ -0,0 +1,76 @@
+package signed
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// GenerateKey creates a key pair for signing
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// Sign returns the base64 encoded detached signature of data
+func Sign(key ed25519.PrivateKey, data []byte) []byte {
+	return encode(ed25519.Sign(key, data))
+}
+
+// EncodeKey encodes a public or private key as a base64 line
+func EncodeKey(key []byte) []byte {
+	return encode(key)
+}
+
+// ParsePublicKey decodes a base64 encoded public key
+func ParsePublicKey(b []byte) (ed25519.PublicKey, error) {
+	key, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("bad public key length %d", len(key))
+	}
+	return key, nil
+}
+
+// ParsePrivateKey decodes a base64 encoded private key or its seed
+func ParsePrivateKey(b []byte) (ed25519.PrivateKey, error) {
+	key, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return key, nil
+	}
+	return nil, fmt.Errorf("bad private key length %d", len(key))
+}
+
+// decodeSignature accepts a raw or a base64 encoded signature
+func decodeSignature(b []byte) ([]byte, error) {
+	if len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+
+	sig, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("bad signature length %d", len(sig))
+	}
+	return sig, nil
+}
+
+func encode(b []byte) []byte {
+	return append([]byte(base64.StdEncoding.EncodeToString(b)), '\n')
+}
+
+func decode(b []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+}
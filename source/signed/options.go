@@ -0,0 +1,34 @@
+package signed
+
+import (
+	"context"
+	"crypto/ed25519"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type publicKeysKey struct{}
+
+type signatureKey struct{}
+
+// WithPublicKey adds the keys a signature is accepted from, e.g. the current and the next key during a rotation
+func WithPublicKey(keys ...ed25519.PublicKey) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		prev, _ := o.Context.Value(publicKeysKey{}).([]ed25519.PublicKey)
+		all := append(prev[:len(prev):len(prev)], keys...)
+		o.Context = context.WithValue(o.Context, publicKeysKey{}, all)
+	}
+}
+
+// WithSignature sets the source of the detached signature, e.g. a file source of config.json.sig
+func WithSignature(s source.Source) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, signatureKey{}, s)
+	}
+}
@@ -0,0 +1,182 @@
+// Package signed verifies the detached Ed25519 signatures of the change sets of another source
+package signed
+
+import (
+	"crypto/ed25519"
+	"log/slog"
+	"sync"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnsigned is returned when the signature of a change set can not be read
+	ErrUnsigned = errors.New("unsigned payload")
+	// ErrInvalidSignature is returned when the signature doesn't match any of the public keys
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+type signed struct {
+	src  source.Source
+	sig  source.Source
+	keys []ed25519.PublicKey
+	opts source.Options
+}
+
+// Read reads the payload and rejects it unless the signature is valid
+func (s *signed) Read() (*source.ChangeSet, error) {
+	cs, err := s.src.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.verify(cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// verify checks the current signature against the data of the change set
+func (s *signed) verify(cs *source.ChangeSet) error {
+	if s.sig == nil {
+		return errors.Wrapf(ErrUnsigned, "%s: no signature source", s)
+	}
+
+	sc, err := s.sig.Read()
+	if err != nil {
+		return errors.Wrapf(ErrUnsigned, "%s: %v", s, err)
+	}
+
+	sig, err := decodeSignature(sc.Data)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "%s: %v", s, err)
+	}
+
+	for _, key := range s.keys {
+		if ed25519.Verify(key, cs.Data, sig) {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrInvalidSignature, "%s", s)
+}
+
+// Watch watches both the payload and the signature, the changes without a valid signature are dropped
+func (s *signed) Watch() (source.Watcher, error) {
+	return newWatcher(s)
+}
+
+// Write is unsupported, the data couldn't be signed
+func (s *signed) Write(*source.ChangeSet) error {
+	return errors.New("signed source is read only")
+}
+
+// String is the name of the wrapped source unless named
+func (s *signed) String() string {
+	if s.opts.Name != "" {
+		return s.opts.Name
+	}
+	return s.src.String()
+}
+
+// NewSource wraps src, every change set must be signed by one of the keys of WithPublicKey.
+// The detached signature is read from the source of WithSignature.
+func NewSource(src source.Source, opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	s := &signed{src: src, opts: options}
+	s.keys, _ = options.Context.Value(publicKeysKey{}).([]ed25519.PublicKey)
+	s.sig, _ = options.Context.Value(signatureKey{}).(source.Source)
+	return s
+}
+
+type update struct {
+	cs  *source.ChangeSet
+	err error
+}
+
+type watcher struct {
+	s        *signed
+	watchers []source.Watcher
+	updates  chan update
+	exit     chan bool
+	once     sync.Once
+}
+
+func newWatcher(s *signed) (source.Watcher, error) {
+	pw, err := s.src.Watch()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		s:        s,
+		watchers: []source.Watcher{pw},
+		updates:  make(chan update),
+		exit:     make(chan bool),
+	}
+
+	go w.run(pw, func(cs *source.ChangeSet) (*source.ChangeSet, error) {
+		return cs, s.verify(cs)
+	})
+
+	// the payload may change before its signature, so a new signature checks the payload again
+	if s.sig != nil {
+		if sw, err := s.sig.Watch(); err == nil {
+			w.watchers = append(w.watchers, sw)
+			go w.run(sw, func(*source.ChangeSet) (*source.ChangeSet, error) {
+				return s.Read()
+			})
+		}
+	}
+
+	return w, nil
+}
+
+func (w *watcher) run(sw source.Watcher, check func(*source.ChangeSet) (*source.ChangeSet, error)) {
+	for {
+		cs, err := sw.Next()
+		if err == nil {
+			if cs, err = check(cs); err != nil {
+				slog.Error("signed change set rejected.",
+					slog.String("source", w.s.String()),
+					slog.String("err", err.Error()))
+				continue
+			}
+		}
+
+		select {
+		case w.updates <- update{cs: cs, err: err}:
+		case <-w.exit:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Next returns the next change set with a valid signature
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case u := <-w.updates:
+		return u.cs, u.err
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop stops the payload and the signature watchers
+func (w *watcher) Stop() error {
+	var err error
+	w.once.Do(func() {
+		close(w.exit)
+		for _, sw := range w.watchers {
+			if e := sw.Stop(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
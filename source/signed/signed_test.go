@@ -0,0 +1,97 @@
+package signed_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/nextpkg/nextcfg/source/file"
+	"github.com/nextpkg/nextcfg/source/memory"
+	"github.com/nextpkg/nextcfg/source/signed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigned(t *testing.T) {
+	at := require.New(t)
+
+	pub, priv, err := signed.GenerateKey()
+	at.Nil(err)
+	next, _, err := signed.GenerateKey()
+	at.Nil(err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	data := []byte(`{"foo": "bar"}`)
+	at.Nil(os.WriteFile(path, data, 0o600))
+
+	s := signed.NewSource(file.NewSource(file.WithPath(path)),
+		signed.WithPublicKey(next),
+		signed.WithPublicKey(pub),
+		signed.WithSignature(file.NewSource(file.WithPath(path+".sig"))),
+	)
+	at.Equal("file:"+path, s.String())
+
+	_, err = s.Read()
+	at.ErrorIs(err, signed.ErrUnsigned)
+
+	at.Nil(os.WriteFile(path+".sig", signed.Sign(priv, data), 0o600))
+	cs, err := s.Read()
+	at.Nil(err)
+	at.Equal(data, cs.Data)
+
+	// tampered
+	at.Nil(os.WriteFile(path, []byte(`{"foo": "baz"}`), 0o600))
+	_, err = s.Read()
+	at.ErrorIs(err, signed.ErrInvalidSignature)
+
+	// signed by an unknown key
+	s = signed.NewSource(file.NewSource(file.WithPath(path)),
+		signed.WithPublicKey(next),
+		signed.WithSignature(file.NewSource(file.WithPath(path+".sig"))),
+	)
+	at.Nil(os.WriteFile(path, data, 0o600))
+	_, err = s.Read()
+	at.ErrorIs(err, signed.ErrInvalidSignature)
+}
+
+func TestSignedWatch(t *testing.T) {
+	at := require.New(t)
+
+	pub, priv, err := signed.GenerateKey()
+	at.Nil(err)
+
+	v1 := []byte(`{"foo": "v1"}`)
+	v2 := []byte(`{"foo": "v2"}`)
+	payload := memory.NewSource(memory.WithJSON(v1))
+	sig := memory.NewSource(memory.WithChangeSet(&source.ChangeSet{Data: signed.Sign(priv, v1)}))
+
+	s := signed.NewSource(payload, signed.WithPublicKey(pub), signed.WithSignature(sig))
+	w, err := s.Watch()
+	at.Nil(err)
+	defer func() {
+		at.Nil(w.Stop())
+	}()
+
+	next := make(chan *source.ChangeSet, 1)
+	go func() {
+		cs, err := w.Next()
+		if err == nil {
+			next <- cs
+		}
+	}()
+
+	// the payload changes before the signature and is rejected until the signature follows
+	at.Nil(payload.Write(&source.ChangeSet{Data: v2, Format: "json"}))
+	time.Sleep(50 * time.Millisecond)
+	at.Empty(next)
+
+	at.Nil(sig.Write(&source.ChangeSet{Data: signed.Sign(priv, v2)}))
+	select {
+	case cs := <-next:
+		at.Equal(v2, cs.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("signed change set timeout")
+	}
+}
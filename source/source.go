@@ -9,6 +9,15 @@ import (
 var (
 	// ErrWatcherStopped is returned when source watcher has been stopped
 	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrChecksumMismatch is returned when the data doesn't match the expected digest
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+const (
+	// FormatMergePatch is a JSON merge patch (RFC 7396) of the earlier change sets
+	FormatMergePatch = "merge-patch"
+	// FormatJSONPatch is a JSON patch (RFC 6902) of the earlier change sets
+	FormatJSONPatch = "json-patch"
 )
 
 // Source is the source from which config is loaded
@@ -21,8 +30,10 @@ type Source interface {
 
 // ChangeSet represents a set of changes from a source
 type ChangeSet struct {
-	Data      []byte
-	Checksum  string
+	Data     []byte
+	Checksum string
+	// Algorithm of the checksum, DefaultChecksum if empty
+	Algorithm string
 	Format    string
 	Source    string
 	Timestamp time.Time
@@ -3,8 +3,10 @@ package url
 
 import (
 	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/registry"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -19,17 +21,20 @@ import (
 // sourceName 数据源名称
 const sourceName = "url"
 
+// checksumHeader is followed by the checksum algorithm, the value is the hex digest of the body
+const checksumHeader = "X-Checksum-"
+
 // DefaultURL 默认目标
 var DefaultURL = "http://config-center/render/zhiwei/" + filepath.Base(os.Args[0])
 
 func init() {
-	registry.SetDefaultSource(sourceName)
-	cmd.AddSubFlags(registry.From, sourceName, func() *cmd.FlagSet {
-		fs := cmd.NewFlagSet("--source=url", pflag.ContinueOnError)
+	registry.SetCfgSource(sourceName)
+	cmd.AddSubFlags(registry.CfgFlag, sourceName, func() *cmd.FlagSet {
+		fs := cmd.NewFlagSet("--cfg=url", pflag.ContinueOnError)
 		fs.StringVar(&DefaultURL, "config_address", DefaultURL, "url system target address")
 		return fs
 	})
-	registry.SetToHub(sourceName, func(target string) nextcfg.Loader {
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
 		if target != "" {
 			target = DefaultURL + "/" + target
 		} else {
@@ -72,8 +77,18 @@ func (u *urlSource) Read() (*source.ChangeSet, error) {
 		Format:    formatUrl(u.url, u.opts),
 		Timestamp: time.Now(),
 		Source:    u.String(),
+		Algorithm: u.opts.Checksum,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
+
+	if u.opts.Integrity {
+		// e.g. X-Checksum-Sha256 as sent by the artifact repositories
+		if err = cs.Verify(rsp.Header.Get(checksumHeader + cs.Algorithm)); err != nil {
+			return nil, err
+		}
 	}
-	cs.Checksum = cs.Sum()
 
 	return cs, nil
 }
@@ -90,7 +105,14 @@ func (u *urlSource) Write(*source.ChangeSet) error {
 
 // String URL
 func (u *urlSource) String() string {
-	return sourceName
+	if u.opts.Name != "" {
+		return u.opts.Name
+	}
+	// the password of the url is masked
+	if v, err := neturl.Parse(u.url); err == nil {
+		return sourceName + ":" + v.Redacted()
+	}
+	return sourceName + ":" + u.url
 }
 
 // NewSource URL数据源
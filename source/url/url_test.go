@@ -0,0 +1,51 @@
+package url
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrity(t *testing.T) {
+	at := assert.New(t)
+
+	data := []byte(`{"foo": "bar"}`)
+	digest, err := source.Checksum(source.SHA256, data)
+	at.Nil(err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good.json":
+			w.Header().Set("X-Checksum-Sha256", digest)
+		case "/bad.json":
+			w.Header().Set("X-Checksum-Sha256", "00"+digest[2:])
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	cs, err := NewSource(WithURL(srv.URL+"/good.json"), source.WithIntegrity()).Read()
+	at.Nil(err)
+	at.Equal(digest, cs.Checksum)
+	at.Equal(source.SHA256, cs.Algorithm)
+
+	_, err = NewSource(WithURL(srv.URL+"/bad.json"), source.WithIntegrity()).Read()
+	at.ErrorIs(err, source.ErrChecksumMismatch)
+
+	_, err = NewSource(WithURL(srv.URL+"/none.json"), source.WithIntegrity()).Read()
+	at.NotNil(err)
+
+	// without the integrity mode the digest is optional
+	cs, err = NewSource(WithURL(srv.URL+"/none.json"), source.WithChecksum(source.XXHash)).Read()
+	at.Nil(err)
+	at.Equal(source.XXHash, cs.Algorithm)
+	at.Len(cs.Checksum, 16)
+
+	// an algorithm which is not registered fails the read instead of falling back to sha256
+	_, err = NewSource(WithURL(srv.URL+"/none.json"), source.WithChecksum("md5")).Read()
+	at.NotNil(err)
+	at.Contains(err.Error(), `unknown checksum algorithm "md5"`)
+}
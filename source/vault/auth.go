@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultServiceAccountToken is where kubernetes mounts the token of the service account
+var DefaultServiceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// authMethod obtains a vault token
+type authMethod interface {
+	login(v *vault) (*authResponse, error)
+}
+
+type tokenAuth struct {
+	token string
+}
+
+// login returns the token as is, it's not renewed
+func (t *tokenAuth) login(*vault) (*authResponse, error) {
+	if t.token == "" {
+		return nil, errors.New("vault token required")
+	}
+	return &authResponse{ClientToken: t.token}, nil
+}
+
+type appRoleAuth struct {
+	mount    string
+	roleID   string
+	secretID string
+}
+
+func (a *appRoleAuth) login(v *vault) (*authResponse, error) {
+	var rsp secretResponse
+	err := v.call("POST", "auth/"+a.mount+"/login", map[string]string{
+		"role_id":   a.roleID,
+		"secret_id": a.secretID,
+	}, &rsp)
+	if err != nil {
+		return nil, errors.Wrap(err, "approle login failed")
+	}
+	if rsp.Auth == nil {
+		return nil, errors.New("approle login returned no token")
+	}
+	return rsp.Auth, nil
+}
+
+type kubernetesAuth struct {
+	mount   string
+	role    string
+	jwtPath string
+}
+
+func (k *kubernetesAuth) login(v *vault) (*authResponse, error) {
+	// read on every login, the token of the service account is rotated by kubernetes
+	jwt, err := os.ReadFile(k.jwtPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "read service account token failed")
+	}
+
+	var rsp secretResponse
+	err = v.call("POST", "auth/"+k.mount+"/login", map[string]string{
+		"role": k.role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}, &rsp)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubernetes login failed")
+	}
+	if rsp.Auth == nil {
+		return nil, errors.New("kubernetes login returned no token")
+	}
+	return rsp.Auth, nil
+}
@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type addressKey struct{}
+type mountKey struct{}
+type pathsKey struct{}
+type authKey struct{}
+type intervalKey struct{}
+type clientKey struct{}
+type timeoutKey struct{}
+
+// mountPath is a secret path and the key path of its data in the config tree
+type mountPath struct {
+	path string
+	at   []string
+	// kv is false for the logical paths, e.g. the dynamic secrets of database/creds/<role>
+	kv bool
+}
+
+// WithAddress sets the vault address, defaults to $VAULT_ADDR or http://127.0.0.1:8200
+func WithAddress(a string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, addressKey{}, a)
+	}
+}
+
+// WithMount sets the mount of the KV v2 engine, defaults to secret
+func WithMount(m string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, mountKey{}, m)
+	}
+}
+
+// WithPath adds a KV v2 path under the mount, its data is put at the key path at.
+// at defaults to the segments of the path, a single "" merges the data into the root.
+func WithPath(path string, at ...string) source.Option {
+	return withPath(mountPath{path: path, at: at, kv: true})
+}
+
+// WithSecret adds a logical path read as is, e.g. the leased dynamic secrets of database/creds/<role>.
+// The leases are renewed before expiry, and the secret is read again when they can't be.
+func WithSecret(path string, at ...string) source.Option {
+	return withPath(mountPath{path: path, at: at})
+}
+
+func withPath(p mountPath) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+
+		p.path = strings.Trim(p.path, "/")
+		if len(p.at) == 0 {
+			p.at = strings.Split(p.path, "/")
+		} else if len(p.at) == 1 && p.at[0] == "" {
+			p.at = nil
+		}
+
+		prev, _ := o.Context.Value(pathsKey{}).([]mountPath)
+		o.Context = context.WithValue(o.Context, pathsKey{}, append(prev[:len(prev):len(prev)], p))
+	}
+}
+
+// WithToken authenticates with a token, defaults to $VAULT_TOKEN
+func WithToken(token string) source.Option {
+	return withAuth(&tokenAuth{token: token})
+}
+
+// WithAppRole logs in with the AppRole auth method mounted at approle
+func WithAppRole(roleID, secretID string) source.Option {
+	return withAuth(&appRoleAuth{mount: "approle", roleID: roleID, secretID: secretID})
+}
+
+// WithKubernetes logs in with the service account token of the pod, by the Kubernetes auth method mounted at kubernetes
+func WithKubernetes(role string) source.Option {
+	return withAuth(&kubernetesAuth{mount: "kubernetes", role: role, jwtPath: DefaultServiceAccountToken})
+}
+
+func withAuth(a authMethod) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, authKey{}, a)
+	}
+}
+
+// WithInterval sets how often the versions of the KV paths are checked, defaults to 30s
+func WithInterval(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, intervalKey{}, d)
+	}
+}
+
+// WithTimeout sets the deadline of every request to vault, defaults to 10s
+func WithTimeout(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, timeoutKey{}, d)
+	}
+}
+
+// WithHTTPClient sets the http client, e.g. with the tls config of vault.
+// The requests have the deadline of WithTimeout whatever the timeout of the client.
+func WithHTTPClient(c *http.Client) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, clientKey{}, c)
+	}
+}
@@ -0,0 +1,464 @@
+// Package vault reads the config from the KV v2 and the dynamic secrets of HashiCorp Vault
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/cmd"
+	"github.com/nextpkg/nextcfg/registry"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// sourceName 数据源名称
+const sourceName = "vault"
+
+// Predefined variables
+var (
+	DefaultAddress  = "http://127.0.0.1:8200"
+	DefaultMount    = "secret"
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 10 * time.Second
+	DefaultToken    = os.Getenv("VAULT_TOKEN")
+	DefaultRoleID   = ""
+	DefaultSecretID = ""
+	DefaultK8sRole  = ""
+)
+
+func init() {
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		DefaultAddress = addr
+	}
+
+	registry.SetCfgSource(sourceName)
+
+	// 此处依赖于registry的初始化参数--cfg
+	cmd.AddSubFlags(registry.CfgFlag, sourceName, func() *cmd.FlagSet {
+		fs := cmd.NewFlagSet("--cfg=vault", pflag.ContinueOnError)
+		fs.StringVar(&DefaultAddress, "config_address", DefaultAddress, "vault address")
+		fs.StringVar(&DefaultMount, "config_mount", DefaultMount, "vault kv v2 mount")
+		fs.DurationVar(&DefaultInterval, "config_interval", DefaultInterval, "vault version check interval")
+		fs.DurationVar(&DefaultTimeout, "config_timeout", DefaultTimeout, "vault request timeout")
+		fs.StringVar(&DefaultRoleID, "config_role_id", DefaultRoleID, "vault approle role id")
+		fs.StringVar(&DefaultSecretID, "config_secret_id", DefaultSecretID, "vault approle secret id")
+		fs.StringVar(&DefaultK8sRole, "config_k8s_role", DefaultK8sRole, "vault kubernetes auth role")
+		return fs
+	})
+
+	// 注册，target是逗号分隔的kv路径，数据合并到根
+	registry.SetCfgLoader(sourceName, func(target string) nextcfg.Loader {
+		opts := []source.Option{WithToken(DefaultToken)}
+		switch {
+		case DefaultRoleID != "":
+			opts = []source.Option{WithAppRole(DefaultRoleID, DefaultSecretID)}
+		case DefaultK8sRole != "":
+			opts = []source.Option{WithKubernetes(DefaultK8sRole)}
+		}
+		for _, p := range strings.Split(target, ",") {
+			if p != "" {
+				opts = append(opts, WithPath(p, ""))
+			}
+		}
+		return GetLoader(opts...)
+	})
+}
+
+type vault struct {
+	addr     string
+	mount    string
+	paths    []mountPath
+	auth     authMethod
+	client   *http.Client
+	interval time.Duration
+	// the deadline of every request, the lock is held meanwhile
+	timeout time.Duration
+	opts    source.Options
+
+	// guards the token and the state of the last read
+	sync.Mutex
+	token      string
+	tokenLease *lease
+	versions   map[string]int
+	leases     map[string]*lease
+	secrets    map[string]map[string]interface{}
+	sum        string
+}
+
+// lease of a token or a dynamic secret
+type lease struct {
+	id        string
+	renewable bool
+	duration  time.Duration
+	renewAt   time.Time
+	// the failed renewals and re-reads since the lease was issued
+	failures int
+}
+
+// newLease returns nil for the secrets without lease, they're renewed at 2/3 of the duration
+func newLease(id string, seconds int, renewable bool) *lease {
+	if seconds <= 0 {
+		return nil
+	}
+	d := time.Duration(seconds) * time.Second
+	return &lease{id: id, renewable: renewable, duration: d, renewAt: time.Now().Add(d * 2 / 3)}
+}
+
+// postpone delays the next attempt after a failed renewal or re-read,
+// the delay doubles from a second up to max so a failing vault isn't hammered
+func (l *lease) postpone(max time.Duration) {
+	d := max
+	if l.failures < 30 && time.Second<<l.failures < max {
+		d = time.Second << l.failures
+	}
+	l.failures++
+	l.renewAt = time.Now().Add(d)
+}
+
+type authResponse struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+type secretResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *authResponse          `json:"auth"`
+}
+
+type kvResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+type metadataResponse struct {
+	Data struct {
+		CurrentVersion int `json:"current_version"`
+	} `json:"data"`
+}
+
+// apiError is a non 2xx response of vault
+type apiError struct {
+	status int
+	errors []string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("vault: %d %s", e.status, strings.Join(e.errors, "; "))
+}
+
+// call sends a request to /v1/path with the current token, callers hold the lock
+func (v *vault) call(method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(v.addr, "/")+"/v1/"+path, r)
+	if err != nil {
+		return err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+
+	rsp, err := v.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s failed", method, path)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode/100 != 2 {
+		e := &apiError{status: rsp.StatusCode}
+		var msg struct {
+			Errors []string `json:"errors"`
+		}
+		if json.NewDecoder(rsp.Body).Decode(&msg) == nil {
+			e.errors = msg.Errors
+		}
+		return errors.Wrapf(e, "%s %s", method, path)
+	}
+
+	if out == nil || rsp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
+
+// request is call with a token, it logs in again once when the token is rejected
+func (v *vault) request(method, path string, body, out interface{}) error {
+	if v.token == "" {
+		if err := v.login(); err != nil {
+			return err
+		}
+	}
+
+	err := v.call(method, path, body, out)
+
+	var e *apiError
+	if _, static := v.auth.(*tokenAuth); !static && errors.As(err, &e) && e.status == http.StatusForbidden {
+		if err = v.login(); err != nil {
+			return err
+		}
+		return v.call(method, path, body, out)
+	}
+	return err
+}
+
+func (v *vault) login() error {
+	v.token = ""
+	a, err := v.auth.login(v)
+	if err != nil {
+		return err
+	}
+	v.token = a.ClientToken
+	v.tokenLease = newLease("", a.LeaseDuration, a.Renewable)
+	return nil
+}
+
+// Read the KV v2 paths and the secrets into one tree
+func (v *vault) Read() (*source.ChangeSet, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	tree := make(map[string]interface{})
+	for _, p := range v.paths {
+		var data map[string]interface{}
+
+		if p.kv {
+			var rsp kvResponse
+			if err := v.request("GET", v.mount+"/data/"+p.path, nil, &rsp); err != nil {
+				return nil, err
+			}
+			data = rsp.Data.Data
+			v.versions[p.path] = rsp.Data.Metadata.Version
+		} else if cached, ok := v.secrets[p.path]; ok {
+			// every read of a dynamic secret issues new credentials, so it's kept until its lease ends
+			data = cached
+		} else {
+			var rsp secretResponse
+			if err := v.request("GET", p.path, nil, &rsp); err != nil {
+				return nil, err
+			}
+			data = rsp.Data
+			v.secrets[p.path] = data
+			v.leases[p.path] = newLease(rsp.LeaseID, rsp.LeaseDuration, rsp.Renewable)
+		}
+
+		put(tree, p.at, data)
+	}
+
+	b, err := v.opts.Encoder.Encode(tree)
+	if err != nil {
+		return nil, fmt.Errorf("error reading source: %v", err)
+	}
+
+	cs := &source.ChangeSet{
+		Timestamp: time.Now(),
+		Format:    v.opts.Encoder.String(),
+		Source:    v.String(),
+		Algorithm: v.opts.Checksum,
+		Data:      b,
+	}
+	if cs.Checksum, err = cs.Sum(); err != nil {
+		return nil, err
+	}
+	v.sum = cs.Checksum
+
+	return cs, nil
+}
+
+// put merges the data into the tree at the key path
+func put(tree map[string]interface{}, at []string, data map[string]interface{}) {
+	for _, k := range at {
+		next, ok := tree[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			tree[k] = next
+		}
+		tree = next
+	}
+	for k, val := range data {
+		tree[k] = val
+	}
+}
+
+// refresh renews the due leases and checks the versions of the KV paths,
+// it reports whether the paths have to be read again
+func (v *vault) refresh() bool {
+	v.Lock()
+	defer v.Unlock()
+
+	now := time.Now()
+
+	// the login token, a new one is obtained at the next request if it can't be renewed
+	if l := v.tokenLease; l != nil && now.After(l.renewAt) {
+		var rsp secretResponse
+		if !l.renewable || v.call("POST", "auth/token/renew-self",
+			map[string]int{"increment": int(l.duration.Seconds())}, &rsp) != nil || rsp.Auth == nil {
+			v.token, v.tokenLease = "", nil
+		} else {
+			v.tokenLease = newLease("", rsp.Auth.LeaseDuration, rsp.Auth.Renewable)
+		}
+	}
+
+	changed := false
+	for _, p := range v.paths {
+		if p.kv {
+			var rsp metadataResponse
+			if err := v.request("GET", v.mount+"/metadata/"+p.path, nil, &rsp); err != nil {
+				// e.g. no permission on the metadata, compared by checksum after reading
+				changed = true
+			} else if rsp.Data.CurrentVersion != v.versions[p.path] {
+				changed = true
+			}
+			continue
+		}
+
+		l := v.leases[p.path]
+		if l == nil || now.Before(l.renewAt) {
+			continue
+		}
+		// the secret is read again, the lease is replaced by then unless the read fails
+		if !l.renewable {
+			delete(v.secrets, p.path)
+			l.postpone(v.interval)
+			changed = true
+			continue
+		}
+
+		var rsp secretResponse
+		err := v.request("PUT", "sys/leases/renew", map[string]interface{}{
+			"lease_id":  l.id,
+			"increment": int(l.duration.Seconds()),
+		}, &rsp)
+		// close to the max ttl the renewals are cut short, so the secret is replaced in time
+		if err != nil || time.Duration(rsp.LeaseDuration)*time.Second < l.duration/3 {
+			delete(v.secrets, p.path)
+			l.renewable = false
+			l.postpone(v.interval)
+			changed = true
+			continue
+		}
+		v.leases[p.path] = newLease(l.id, rsp.LeaseDuration, rsp.Renewable)
+	}
+
+	return changed
+}
+
+// next returns when refresh is due
+func (v *vault) next() time.Time {
+	v.Lock()
+	defer v.Unlock()
+
+	at := time.Now().Add(v.interval)
+	for _, l := range append([]*lease{v.tokenLease}, values(v.leases)...) {
+		if l != nil && l.renewAt.Before(at) {
+			at = l.renewAt
+		}
+	}
+	return at
+}
+
+func values(m map[string]*lease) []*lease {
+	ls := make([]*lease, 0, len(m))
+	for _, l := range m {
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+// Write is unsupported
+func (v *vault) Write(*source.ChangeSet) error {
+	return nil
+}
+
+// String vault
+func (v *vault) String() string {
+	if v.opts.Name != "" {
+		return v.opts.Name
+	}
+	return sourceName + ":" + v.mount
+}
+
+// Watch renews the leases and checks the versions of the paths
+func (v *vault) Watch() (source.Watcher, error) {
+	return newWatcher(v)
+}
+
+// NewSource creates a new vault source
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	v := &vault{
+		addr:     DefaultAddress,
+		mount:    DefaultMount,
+		auth:     &tokenAuth{token: DefaultToken},
+		client:   http.DefaultClient,
+		interval: DefaultInterval,
+		timeout:  DefaultTimeout,
+		opts:     options,
+		versions: make(map[string]int),
+		leases:   make(map[string]*lease),
+		secrets:  make(map[string]map[string]interface{}),
+	}
+
+	if a, ok := options.Context.Value(addressKey{}).(string); ok && a != "" {
+		v.addr = a
+	}
+	if m, ok := options.Context.Value(mountKey{}).(string); ok && m != "" {
+		v.mount = strings.Trim(m, "/")
+	}
+	if a, ok := options.Context.Value(authKey{}).(authMethod); ok {
+		v.auth = a
+	}
+	if c, ok := options.Context.Value(clientKey{}).(*http.Client); ok {
+		v.client = c
+	}
+	if d, ok := options.Context.Value(intervalKey{}).(time.Duration); ok && d > 0 {
+		v.interval = d
+	}
+	if d, ok := options.Context.Value(timeoutKey{}).(time.Duration); ok && d > 0 {
+		v.timeout = d
+	}
+	v.paths, _ = options.Context.Value(pathsKey{}).([]mountPath)
+
+	return v
+}
+
+// GetLoader sets vault source
+func GetLoader(opts ...source.Option) nextcfg.Loader {
+	return func(l *nextcfg.Loaders) {
+		err := l.GetCfg().Load(NewSource(opts...))
+		if err != nil {
+			log.Println(err)
+		} else {
+			l.GetCfg().SetState(true)
+		}
+	}
+}
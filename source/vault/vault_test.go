@@ -0,0 +1,223 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault is a stand-in of the vault http api
+type fakeVault struct {
+	sync.Mutex
+	t        *testing.T
+	version  int
+	kv       map[string]interface{}
+	creds    int
+	renewals int
+	maxRenew int
+	logins   map[string]int
+	// the renewals tried and whether the secret reads fail
+	attempts  int
+	failCreds bool
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	reply := func(v interface{}) {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	switch r.URL.Path {
+	case "/v1/auth/approle/login", "/v1/auth/kubernetes/login":
+		if body["role_id"] != "role" && body["jwt"] != "jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.logins[r.URL.Path]++
+		reply(map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": "t", "lease_duration": 3600, "renewable": true,
+		}})
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != "t" {
+		w.WriteHeader(http.StatusForbidden)
+		reply(map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/secret/data/app/db":
+		reply(map[string]interface{}{"data": map[string]interface{}{
+			"data":     f.kv,
+			"metadata": map[string]interface{}{"version": f.version},
+		}})
+	case "/v1/secret/metadata/app/db":
+		reply(map[string]interface{}{"data": map[string]interface{}{"current_version": f.version}})
+	case "/v1/database/creds/app":
+		if f.failCreds {
+			w.WriteHeader(http.StatusInternalServerError)
+			reply(map[string]interface{}{"errors": []string{"internal error"}})
+			return
+		}
+		f.creds++
+		reply(map[string]interface{}{
+			"lease_id":       fmt.Sprintf("database/creds/app/%d", f.creds),
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]interface{}{"username": fmt.Sprintf("u%d", f.creds)},
+		})
+	case "/v1/sys/leases/renew":
+		f.attempts++
+		if f.renewals >= f.maxRenew {
+			w.WriteHeader(http.StatusBadRequest)
+			reply(map[string]interface{}{"errors": []string{"lease expired"}})
+			return
+		}
+		f.renewals++
+		reply(map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": 1, "renewable": true})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestVault(t *testing.T) {
+	at := require.New(t)
+
+	f := &fakeVault{t: t, version: 1, kv: map[string]interface{}{"host": "db", "port": 5432}, maxRenew: 1,
+		logins: map[string]int{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	src := NewSource(
+		WithAddress(srv.URL),
+		WithAppRole("role", "secret"),
+		WithPath("app/db", "db"),
+		WithSecret("database/creds/app"),
+		WithInterval(50*time.Millisecond),
+	)
+
+	cs, err := src.Read()
+	at.Nil(err)
+	at.JSONEq(`{"db": {"host": "db", "port": 5432}, "database": {"creds": {"app": {"username": "u1"}}}}`, string(cs.Data))
+
+	w, err := src.Watch()
+	at.Nil(err)
+	defer w.Stop()
+
+	next := func() *source.ChangeSet {
+		ch := make(chan *source.ChangeSet, 1)
+		go func() {
+			cs, _ := w.Next()
+			ch <- cs
+		}()
+		select {
+		case cs := <-ch:
+			return cs
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch timeout")
+		}
+		return nil
+	}
+
+	// a new version of the kv path
+	f.Lock()
+	f.version, f.kv = 2, map[string]interface{}{"host": "db2", "port": 5432}
+	f.Unlock()
+	at.JSONEq(`{"db": {"host": "db2", "port": 5432}, "database": {"creds": {"app": {"username": "u1"}}}}`,
+		string(next().Data))
+
+	// the lease is renewed once, then the secret is read again before it expires
+	at.JSONEq(`{"db": {"host": "db2", "port": 5432}, "database": {"creds": {"app": {"username": "u2"}}}}`,
+		string(next().Data))
+
+	f.Lock()
+	defer f.Unlock()
+	at.Equal(1, f.renewals)
+	at.Equal(1, f.logins["/v1/auth/approle/login"])
+}
+
+func TestRenewFailing(t *testing.T) {
+	at := require.New(t)
+
+	f := &fakeVault{t: t, logins: map[string]int{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	src := NewSource(WithAddress(srv.URL), WithToken("t"), WithSecret("database/creds/app"), WithInterval(time.Minute))
+
+	_, err := src.Read()
+	at.Nil(err)
+
+	// the renewals and the reads of the secret keep failing
+	f.Lock()
+	f.failCreds = true
+	f.Unlock()
+
+	w, err := src.Watch()
+	at.Nil(err)
+	time.Sleep(2 * time.Second)
+	at.Nil(w.Stop())
+
+	// the lease is due after 2/3s, the renewal fails, then the reads are retried after 1s, 2s...
+	f.Lock()
+	defer f.Unlock()
+	at.Equal(1, f.attempts)
+	at.Equal(1, f.creds)
+}
+
+func TestAuth(t *testing.T) {
+	at := require.New(t)
+
+	f := &fakeVault{t: t, version: 1, kv: map[string]interface{}{"host": "db"}, logins: map[string]int{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	_, err := NewSource(WithAddress(srv.URL), WithToken("bad"), WithPath("app/db")).Read()
+	at.NotNil(err)
+	at.Contains(err.Error(), "permission denied")
+
+	cs, err := NewSource(WithAddress(srv.URL), WithToken("t"), WithPath("app/db")).Read()
+	at.Nil(err)
+	at.JSONEq(`{"app": {"db": {"host": "db"}}}`, string(cs.Data))
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	at.Nil(os.WriteFile(jwt, []byte("jwt\n"), 0o600))
+	DefaultServiceAccountToken = jwt
+
+	cs, err = NewSource(WithAddress(srv.URL), WithKubernetes("app"), WithPath("app/db", "")).Read()
+	at.Nil(err)
+	at.JSONEq(`{"host": "db"}`, string(cs.Data))
+	at.Equal(1, f.logins["/v1/auth/kubernetes/login"])
+}
+
+func TestTimeout(t *testing.T) {
+	at := require.New(t)
+
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer srv.Close()
+	defer close(hang)
+
+	start := time.Now()
+	_, err := NewSource(WithAddress(srv.URL), WithToken("t"), WithPath("app/db"), WithTimeout(100*time.Millisecond)).Read()
+	at.NotNil(err)
+	at.Contains(err.Error(), "context deadline exceeded")
+	at.Less(time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/nextpkg/nextcfg/source"
+)
+
+type watcher struct {
+	v    *vault
+	ch   chan *source.ChangeSet
+	exit chan bool
+}
+
+func newWatcher(v *vault) (*watcher, error) {
+	w := &watcher{
+		v:    v,
+		ch:   make(chan *source.ChangeSet),
+		exit: make(chan bool),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case <-w.exit:
+			return
+		case <-time.After(time.Until(w.v.next())):
+		}
+
+		if !w.v.refresh() {
+			continue
+		}
+
+		w.v.Lock()
+		prev := w.v.sum
+		w.v.Unlock()
+
+		cs, err := w.v.Read()
+		if err != nil {
+			slog.Warn("vault read failed.", slog.String("source", w.v.String()), slog.String("err", err.Error()))
+			continue
+		}
+		if cs.Checksum == prev {
+			continue
+		}
+
+		select {
+		case w.ch <- cs:
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+// Next 处理新配置
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.ch:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+// Stop 关闭监听器
+func (w *watcher) Stop() error {
+	select {
+	case <-w.exit:
+	default:
+		close(w.exit)
+	}
+
+	return nil
+}
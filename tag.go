@@ -0,0 +1,330 @@
+package nextcfg
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a struct tag rule violated by a config key
+type FieldError struct {
+	// Path of the key, dot separated
+	Path string
+	// Message describes the violated rule
+	Message string
+}
+
+// Error ...
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// FieldErrors aggregates every violated struct tag rule
+type FieldErrors []FieldError
+
+// Error ...
+func (e FieldErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyTags sets the `default` of the zero fields whose key is missing in values,
+// then checks the `required`, `min`, `max`, `oneof` and `pattern` rules of the keys which are set.
+func applyTags(v interface{}, values map[string]interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs FieldErrors
+	walkTags(rv.Elem(), values, nil, &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func walkTags(rv reflect.Value, values map[string]interface{}, path []string, errs *FieldErrors) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+
+		name, ok := fieldName(sf)
+		if !ok {
+			continue
+		}
+
+		// embedded structs are flattened like encoding/json does
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Struct {
+				walkTags(fv, values, path, errs)
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		p := append(path[:len(path):len(path)], name)
+		raw, present := lookup(values, name)
+
+		// the default only fills a zero field, the defaults given to New are kept.
+		// A field reached through an unexported embedded struct can not be set.
+		if def, ok := sf.Tag.Lookup("default"); ok && !present && fv.CanSet() {
+			if fv.IsZero() {
+				if err := setString(fv, def); err != nil {
+					*errs = append(*errs, FieldError{strings.Join(p, "."), "invalid default: " + err.Error()})
+					continue
+				}
+			}
+			present = true
+		}
+
+		if sf.Tag.Get("required") == "true" && !present {
+			*errs = append(*errs, FieldError{strings.Join(p, "."), "is required"})
+			continue
+		}
+
+		// an optional key which is missing has nothing to check
+		if present {
+			checkRules(sf, fv, strings.Join(p, "."), errs)
+		}
+
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}):
+			sub, _ := raw.(map[string]interface{})
+			walkTags(fv, sub, p, errs)
+		case fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			sub, _ := raw.(map[string]interface{})
+			walkTags(fv.Elem(), sub, p, errs)
+		case (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() == reflect.Struct:
+			list, _ := raw.([]interface{})
+			for j := 0; j < fv.Len(); j++ {
+				var sub map[string]interface{}
+				if j < len(list) {
+					sub, _ = list[j].(map[string]interface{})
+				}
+				walkTags(fv.Index(j), sub, append(p[:len(p):len(p)], strconv.Itoa(j)), errs)
+			}
+		}
+	}
+}
+
+// fieldName returns the json key of an exported field, "" if it has no explicit name.
+// Like encoding/json, the unexported embedded fields are skipped unless they are structs.
+func fieldName(sf reflect.StructField) (string, bool) {
+	if sf.PkgPath != "" {
+		if !sf.Anonymous {
+			return "", false
+		}
+		t := sf.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return "", false
+		}
+	}
+
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name := strings.Split(tag, ",")[0]
+	return name, true
+}
+
+// lookup finds a key like encoding/json does, preferring an exact match
+func lookup(values map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := values[name]; ok {
+		return v, true
+	}
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func checkRules(sf reflect.StructField, fv reflect.Value, path string, errs *FieldErrors) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if min, ok := sf.Tag.Lookup("min"); ok {
+		if c, err := compare(fv, min); err != nil {
+			fail("invalid min: %v", err)
+		} else if c < 0 {
+			fail("%s >= %s", mustBe(fv), min)
+		}
+	}
+
+	if max, ok := sf.Tag.Lookup("max"); ok {
+		if c, err := compare(fv, max); err != nil {
+			fail("invalid max: %v", err)
+		} else if c > 0 {
+			fail("%s <= %s", mustBe(fv), max)
+		}
+	}
+
+	if oneof, ok := sf.Tag.Lookup("oneof"); ok {
+		options := strings.Fields(oneof)
+		actual := fmt.Sprint(fv.Interface())
+		found := false
+		for _, o := range options {
+			if o == actual {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", options)
+		}
+	}
+
+	if pattern, ok := sf.Tag.Lookup("pattern"); ok && fv.Kind() == reflect.String {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fail("invalid pattern: %v", err)
+		} else if !re.MatchString(fv.String()) {
+			fail("must match %s", pattern)
+		}
+	}
+}
+
+func mustBe(fv reflect.Value) string {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return "length must be"
+	}
+	return "must be"
+}
+
+// compare returns the sign of the field value (or length) minus the limit
+func compare(fv reflect.Value, limit string) (int, error) {
+	sign := func(d float64) int {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+		return 0
+	}
+
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return 0, err
+		}
+		return sign(float64(fv.Len() - n)), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == durationType {
+			d, err := time.ParseDuration(limit)
+			if err != nil {
+				return 0, err
+			}
+			return sign(float64(fv.Int() - int64(d))), nil
+		}
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return sign(float64(fv.Int() - n)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return sign(float64(fv.Uint()) - float64(n)), nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(limit, 64)
+		if err != nil {
+			return 0, err
+		}
+		return sign(fv.Float() - n), nil
+	}
+
+	return 0, fmt.Errorf("unsupported kind %s", fv.Kind())
+}
+
+// setString sets a field from the string form of a default value
+func setString(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setString(fv.Elem(), s)
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setString(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+
+	return nil
+}
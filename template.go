@@ -27,53 +27,39 @@ type Revoke interface {
 
 // GetCopy returns config with watching
 func GetCopy() interface{} {
-	ld.once.Do(func() {
-		err := ld.load(ld.cfg.Get())
-		if nil != err {
-			panic(fmt.Sprintf("GetCopy Failed: %+v", err))
-		}
-
-		go ld.watch()
-	})
+	l := mustDefault()
+	if err := l.start(); nil != err {
+		panic(fmt.Sprintf("GetCopy Failed: %+v", err))
+	}
 
-	return ld.data.Load()
+	return l.data.Load()
 }
 
 // GetOnce returns config once
 func GetOnce() interface{} {
-	ld.once.Do(func() {
-		if err := ld.load(ld.cfg.Get()); nil != err {
-			panic(err)
-		}
-	})
-
-	// reset
-	defer func() {
-		ld.once = sync.Once{}
-	}()
+	return mustDefault().GetOnce()
+}
 
-	return ld.data.Load()
+func mustDefault() *Loaders {
+	l := Default()
+	if l == nil {
+		panic("no default loaders, call Init first or use AsDefault()")
+	}
+	return l
 }
 
 // GetCopy by loaders returns config with watching
 func (l *Loaders) GetCopy() interface{} {
-	l.once.Do(func() {
-		err := l.load(l.cfg.Get())
-		if err != nil {
-			panic(err)
-		}
-
-		go l.watch()
-	})
+	if err := l.start(); err != nil {
+		panic(err)
+	}
 	return l.data.Load()
 }
 
 // GetOnce by loaders returns config once
 func (l *Loaders) GetOnce() interface{} {
 	l.once.Do(func() {
-		if err := l.load(l.cfg.Get()); nil != err {
-			panic(err)
-		}
+		l.err = l.load(l.cfg.Get())
 	})
 
 	// reset
@@ -81,84 +67,176 @@ func (l *Loaders) GetOnce() interface{} {
 		l.once = sync.Once{}
 	}()
 
+	if l.err != nil {
+		panic(l.err)
+	}
+
 	return l.data.Load()
 }
 
 // GetCfg get loader config
 func (l *Loaders) GetCfg() Config {
+	if l.cfg == nil {
+		// created on first use, so that WithConfig can be applied before
+		l.cfg, l.err = NewConfig()
+	}
 	return l.cfg
 }
 
+// start loads the config at the first call and takes part in the reloads of the config
+func (l *Loaders) start() error {
+	l.once.Do(func() {
+		// the Secret fields and the fields tagged `secret:"true"` are redacted in the outputs of the config
+		for _, p := range secretPaths(l.data.Load()) {
+			l.cfg.Redact(p...)
+		}
+
+		// register before loading, so no reload is missed in between
+		unregister := l.cfg.Register(&consumer{l: l})
+
+		l.err = l.load(l.cfg.Get())
+		if l.err != nil {
+			unregister()
+			return
+		}
+
+		go func() {
+			<-l.ctx.Done()
+			unregister()
+		}()
+	})
+
+	return l.err
+}
+
+// onChange registers fn to be called after each successful reload
+func (l *Loaders) onChange(fn func(old, new interface{})) {
+	l.Lock()
+	defer l.Unlock()
+	l.watchers = append(l.watchers, fn)
+}
+
 func (l *Loaders) load(r reader.Value) error {
-	replica := l.data.Load()
+	shadow, err := l.prepare(r)
+	if err != nil {
+		return err
+	}
 
-	shadow := deepcopy.Copy(replica)
+	l.commit(shadow)()
+	return nil
+}
+
+// prepare scans and validates a copy of the current config
+func (l *Loaders) prepare(r reader.Value) (interface{}, error) {
+	if l.schema != nil {
+		if err := l.schema.Validate(reader.Reveal(r)); err != nil {
+			return nil, errors.Wrap(err, "schema validation failed")
+		}
+	}
+
+	shadow := deepcopy.Copy(l.data.Load())
 
 	// l.scan是自定义的配置扫描函数，r.scan是默认的配置扫描函数
-	if l.scan == nil {
+	if l.scan == nil && l.strict {
+		err := reader.ScanStrict(r, shadow)
+		if err != nil {
+			return nil, err
+		}
+	} else if l.scan == nil {
 		err := r.Scan(shadow)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		err := l.scan(r, shadow)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	// struct tag 规则: default, required, min, max, oneof, pattern
+	var values map[string]interface{}
+	if err := r.Scan(&values); err != nil {
+		return nil, err
+	}
+	if err := applyTags(shadow, values); err != nil {
+		return nil, err
+	}
+
 	hi := reflect.TypeOf(shadow)
 	ht := reflect.TypeOf((*Validate)(nil)).Elem()
 	if hi.Implements(ht) {
 		err := shadow.(Validate).Validate()
 		if err != nil {
-			return errors.Wrap(err, "validate() failed")
+			return nil, errors.Wrap(err, "validate() failed")
 		}
 	}
 
+	return shadow, nil
+}
+
+// commit swaps in a prepared config, the returned func revokes the replaced one and calls the watchers
+func (l *Loaders) commit(shadow interface{}) func() {
+	replica := l.data.Load()
+
 	l.data.Store(shadow)
 
-	hi = reflect.TypeOf(replica)
-	ht = reflect.TypeOf((*Revoke)(nil)).Elem()
-	if hi.Implements(ht) {
-		replica.(Revoke).Revoke()
+	l.RLock()
+	watchers := l.watchers
+	l.RUnlock()
+
+	return func() {
+		hi := reflect.TypeOf(replica)
+		ht := reflect.TypeOf((*Revoke)(nil)).Elem()
+		if hi.Implements(ht) {
+			replica.(Revoke).Revoke()
+		}
+
+		for _, fn := range watchers {
+			fn(replica, shadow)
+		}
 	}
+}
 
-	return nil
+// notifier is a consumer with callbacks to run once the swap is done, outside of the transaction
+type notifier interface {
+	// committed returns the callbacks of the last Commit, nil if there are none
+	committed() func()
 }
 
-func (l *Loaders) watch() {
-	w, err := l.cfg.Watch()
+// consumer takes part in the reload transactions of the config for the loaders
+type consumer struct {
+	l       *Loaders
+	pending interface{}
+	done    func()
+}
+
+// Prepare ...
+func (c *consumer) Prepare(r reader.Value) error {
+	shadow, err := c.l.prepare(r)
 	if err != nil {
-		slog.Error("watch failed.", slog.String("err", err.Error()))
+		return err
 	}
 
-	go func() {
-		select {
-		case <-l.ctx.Done():
-			err = w.Stop()
-			if err != nil {
-				slog.Error("stop watcher failed.", slog.String("err", err.Error()))
-			}
-		}
-	}()
+	c.pending = shadow
+	return nil
+}
 
-	for {
-		r, err := w.Next()
-		if err != nil {
-			slog.Error("watch next failed.", slog.String("err", err.Error()))
-			ld.once = sync.Once{}
-			break
-		}
+// Commit ...
+func (c *consumer) Commit() {
+	c.done = c.l.commit(c.pending)
+	c.pending = nil
 
-		slog.Info("Configuration reloading...")
+	slog.Info("Configuration Reloaded!")
+}
 
-		err = l.load(r)
-		if err != nil {
-			slog.Error("load failed.", slog.String("err", err.Error()))
-			continue
-		}
+func (c *consumer) committed() func() {
+	done := c.done
+	c.done = nil
+	return done
+}
 
-		slog.Info("Configuration Reloaded!")
-	}
+// Abort ...
+func (c *consumer) Abort() {
+	c.pending = nil
 }
@@ -1,9 +1,11 @@
-package nextcfg
+package nextcfg_test
 
 import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/source/file"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,9 +29,9 @@ func TestCfg_Get(t *testing.T) {
 	at.Nil(err)
 	at.Nil(f.Close())
 
-	Init(&testCfg{Test1: "dd"}, WithFileSource(f.Name()))
+	nextcfg.Init(&testCfg{Test1: "dd"}, file.GetLoader(f.Name()))
 
-	data := GetCopy().(*testCfg)
+	data := nextcfg.GetCopy().(*testCfg)
 	at.Equal("abcd", data.Test1)
 	at.True(data.is)
 }
@@ -46,8 +48,8 @@ func TestCfg_GetMulti(t *testing.T) {
 	at.Nil(f1.Close())
 	at.Nil(f2.Close())
 
-	loader1 := Init(&testCfg{Test1: "dd"}, WithFileSource(f1.Name()))
-	loader2 := Init(&testCfg{Test1: "ee"}, WithFileSource(f2.Name()))
+	loader1 := nextcfg.Init(&testCfg{Test1: "dd"}, file.GetLoader(f1.Name()))
+	loader2 := nextcfg.Init(&testCfg{Test1: "ee"}, file.GetLoader(f2.Name()))
 
 	data1 := loader1.GetCopy().(*testCfg)
 	data2 := loader2.GetCopy().(*testCfg)
@@ -56,3 +58,33 @@ func TestCfg_GetMulti(t *testing.T) {
 	at.True(data1.is)
 	at.True(data2.is)
 }
+
+func TestLoaders_Isolated(t *testing.T) {
+	at := assert.New(t)
+
+	prev, def := nextcfg.Default(), nextcfg.DefaultConfig
+	t.Cleanup(func() {
+		nextcfg.ResetDefault(prev, def)
+	})
+
+	f1, err := ioutil.TempFile("", "*.yaml")
+	at.Nil(err)
+	_, err = f1.WriteString("test1: plugin")
+	at.Nil(err)
+	at.Nil(f1.Close())
+
+	plugin := nextcfg.InitLoader(file.GetLoader(f1.Name()))
+	at.Nil(plugin.GetCfg().Load())
+	at.Equal(def, nextcfg.DefaultConfig)
+	at.Equal(prev, nextcfg.Default())
+
+	host := nextcfg.InitLoader(nextcfg.AsDefault())
+	at.Equal(host, nextcfg.Default())
+	at.Equal(host.GetCfg(), nextcfg.DefaultConfig)
+	at.NotEqual(plugin.GetCfg(), nextcfg.DefaultConfig)
+	at.Equal("plugin", plugin.GetCfg().Get("test1").String(""))
+	at.Equal("", nextcfg.Get("test1").String(""))
+
+	at.Nil(plugin.Close())
+	at.Nil(host.Close())
+}
@@ -0,0 +1,46 @@
+package nextcfg
+
+// Typed is a type-safe configurator of T built on Loaders
+type Typed[T any] struct {
+	l *Loaders
+}
+
+// New creates a configurator of T, defaults is used for keys no source provides.
+// The config is loaded and watched before New returns, so a failed
+// scan or Validate() is reported here instead of panicking later.
+func New[T any](defaults T, lds ...Loader) (*Typed[T], error) {
+	l, err := newLoaders(lds...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.bind(&defaults); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	if err = l.start(); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return &Typed[T]{l: l}, nil
+}
+
+// Get returns the current config, it must be treated as read-only
+func (t *Typed[T]) Get() *T {
+	return t.l.data.Load().(*T)
+}
+
+// Watch calls fn after each successful reload with the replaced and the new config.
+// fn is called once the reload is done, so it may change the config, e.g. with Set
+func (t *Typed[T]) Watch(fn func(old, new *T)) {
+	t.l.onChange(func(old, new interface{}) {
+		fn(old.(*T), new.(*T))
+	})
+}
+
+// Loaders returns the underlying loaders
+func (t *Typed[T]) Loaders() *Loaders {
+	return t.l
+}
@@ -0,0 +1,334 @@
+package nextcfg_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nextpkg/nextcfg"
+	"github.com/nextpkg/nextcfg/reader"
+	"github.com/nextpkg/nextcfg/source"
+	"github.com/nextpkg/nextcfg/source/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type typedCfg struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+type rejectCfg struct {
+	Port int `json:"port"`
+}
+
+// Validate ...
+func (c *rejectCfg) Validate() error {
+	if c.Port == 0 {
+		return errors.New("port is required")
+	}
+	return nil
+}
+
+func withSource(s source.Source) nextcfg.Loader {
+	return func(l *nextcfg.Loaders) {
+		_ = l.GetCfg().Load(s)
+	}
+}
+
+func TestNew(t *testing.T) {
+	at := require.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"name": "foo"}`)))
+
+	tc, err := nextcfg.New(typedCfg{Port: 80}, withSource(src))
+	at.Nil(err)
+	at.Equal("foo", tc.Get().Name)
+	at.Equal(80, tc.Get().Port)
+
+	changed := make(chan [2]*typedCfg, 1)
+	tc.Watch(func(old, new *typedCfg) {
+		changed <- [2]*typedCfg{old, new}
+	})
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"name": "bar", "port": 8080}`), Format: "json"}))
+
+	select {
+	case c := <-changed:
+		at.Equal("foo", c[0].Name)
+		at.Equal("bar", c[1].Name)
+		at.Equal(8080, c[1].Port)
+		at.Equal(c[1], tc.Get())
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload timeout")
+	}
+}
+
+func TestNewWatchReenter(t *testing.T) {
+	at := require.New(t)
+
+	cfg, err := nextcfg.NewConfig(nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"name": "foo"}`)))))
+	at.Nil(err)
+
+	tc, err := nextcfg.New(typedCfg{}, nextcfg.WithConfig(cfg))
+	at.Nil(err)
+
+	// the watcher derives the port from the name
+	tc.Watch(func(old, new *typedCfg) {
+		if new.Name != old.Name {
+			cfg.Set(len(new.Name), "port")
+		}
+	})
+
+	done := make(chan bool)
+	go func() {
+		cfg.Set("foobar", "name")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("set deadlocked")
+	}
+
+	at.Equal("foobar", tc.Get().Name)
+	at.Eventually(func() bool { return tc.Get().Port == 6 }, 5*time.Second, 10*time.Millisecond)
+	at.Nil(tc.Loaders().Close())
+	at.Nil(cfg.Close())
+}
+
+func TestNewError(t *testing.T) {
+	at := require.New(t)
+
+	_, err := nextcfg.New(rejectCfg{})
+	at.NotNil(err)
+
+	_, err = nextcfg.New(1)
+	at.NotNil(err)
+
+	_, err = nextcfg.New(&typedCfg{})
+	at.NotNil(err)
+
+	// the failed one is closed, the shared config is left open
+	cfg, err := nextcfg.NewConfig(nextcfg.WithSource(memory.NewSource(memory.WithJSON([]byte(`{"port": 0}`)))))
+	at.Nil(err)
+	_, err = nextcfg.New(rejectCfg{}, nextcfg.WithConfig(cfg))
+	at.NotNil(err)
+	at.Equal(0, cfg.Get("port").Int(-1))
+	at.Nil(cfg.Close())
+}
+
+func TestNewSharedConfig(t *testing.T) {
+	at := require.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"name": "foo", "port": 80}`)))
+
+	cfg, err := nextcfg.NewConfig(nextcfg.WithSource(src))
+	at.Nil(err)
+
+	named, err := nextcfg.New(typedCfg{}, nextcfg.WithConfig(cfg))
+	at.Nil(err)
+	strict, err := nextcfg.New(rejectCfg{}, nextcfg.WithConfig(cfg))
+	at.Nil(err)
+
+	events := make(chan nextcfg.ChangeEvent, 1)
+	cancel := cfg.Subscribe(func(ev nextcfg.ChangeEvent) {
+		events <- ev
+	})
+	defer cancel()
+
+	// wait for the source watcher to start
+	time.Sleep(100 * time.Millisecond)
+
+	// rejected by rejectCfg, nobody is upgraded
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"name": "bar", "port": 0}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.NotNil(ev.Err)
+		at.True(ev.Has("name"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("rejection event timeout")
+	}
+
+	at.Equal("foo", named.Get().Name)
+	at.Equal(80, strict.Get().Port)
+	at.Equal("foo", cfg.Get("name").String(""))
+
+	// accepted by both
+	at.Nil(src.Write(&source.ChangeSet{Data: []byte(`{"name": "baz", "port": 81}`), Format: "json"}))
+
+	select {
+	case ev := <-events:
+		at.Nil(ev.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change event timeout")
+	}
+
+	at.Equal("baz", named.Get().Name)
+	at.Equal(81, strict.Get().Port)
+
+	at.Nil(named.Loaders().Close())
+	at.Nil(strict.Loaders().Close())
+	at.Nil(cfg.Close())
+}
+
+type taggedCfg struct {
+	Server struct {
+		Host    string        `json:"host" default:"localhost"`
+		Port    int           `json:"port" default:"80" min:"1" max:"65535"`
+		Timeout time.Duration `json:"timeout" default:"1s"`
+	} `json:"server"`
+	Mode  string   `json:"mode" required:"true" oneof:"debug release"`
+	Name  string   `json:"name" pattern:"^[a-z]+$"`
+	Hosts []string `json:"hosts" default:"a,b"`
+}
+
+func TestNewTags(t *testing.T) {
+	at := require.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"mode": "debug", "name": "foo", "server": {"port": 8080}}`)))
+
+	tc, err := nextcfg.New(taggedCfg{}, withSource(src))
+	at.Nil(err)
+	at.Equal("localhost", tc.Get().Server.Host)
+	at.Equal(8080, tc.Get().Server.Port)
+	at.Equal(time.Second, tc.Get().Server.Timeout)
+	at.Equal([]string{"a", "b"}, tc.Get().Hosts)
+
+	src = memory.NewSource(memory.WithJSON([]byte(`{"name": "Foo", "server": {"port": 70000}}`)))
+
+	_, err = nextcfg.New(taggedCfg{}, withSource(src))
+	at.NotNil(err)
+	at.Contains(err.Error(), "server.port: must be <= 65535")
+	at.Contains(err.Error(), "mode: is required")
+	at.Contains(err.Error(), "name: must match ^[a-z]+$")
+
+	var fe nextcfg.FieldErrors
+	at.True(errors.As(err, &fe))
+	at.Len(fe, 3)
+
+	// the defaults given to New win over the tags
+	defaults := taggedCfg{}
+	defaults.Server.Host = "example.com"
+	defaults.Hosts = []string{"c"}
+	src = memory.NewSource(memory.WithJSON([]byte(`{"mode": "release"}`)))
+
+	tc, err = nextcfg.New(defaults, withSource(src))
+	at.Nil(err)
+	at.Equal("example.com", tc.Get().Server.Host)
+	at.Equal(80, tc.Get().Server.Port)
+	at.Equal([]string{"c"}, tc.Get().Hosts)
+}
+
+type optionalCfg struct {
+	Level string `json:"level" oneof:"a b"`
+	Name  string `json:"name" min:"3"`
+}
+
+func TestNewTagsOptional(t *testing.T) {
+	at := require.New(t)
+
+	// the rules of the missing optional keys are skipped
+	tc, err := nextcfg.New(optionalCfg{}, withSource(memory.NewSource(memory.WithJSON([]byte(`{}`)))))
+	at.Nil(err)
+	at.Empty(tc.Get().Level)
+
+	_, err = nextcfg.New(optionalCfg{}, withSource(memory.NewSource(memory.WithJSON([]byte(`{"level": "", "name": "x"}`)))))
+	at.NotNil(err)
+	at.Contains(err.Error(), "level: must be one of [a b]")
+	at.Contains(err.Error(), "name: length must be >= 3")
+}
+
+type embeddedPort int
+
+type embeddedServer struct {
+	Host string `json:"host" default:"localhost"`
+}
+
+type embeddedBackup struct {
+	Addr string `json:"addr" default:"localhost"`
+}
+
+type embeddedCfg struct {
+	embeddedServer
+	// unexported embedded fields which can not be set
+	embeddedPort    `json:"port" default:"80"`
+	*embeddedBackup `json:"backup" default:"x"`
+	Name            string `json:"name" default:"foo"`
+}
+
+func TestNewTagsEmbedded(t *testing.T) {
+	at := require.New(t)
+
+	tc, err := nextcfg.New(embeddedCfg{}, withSource(memory.NewSource(memory.WithJSON([]byte(`{}`)))))
+	at.Nil(err)
+	at.Equal("localhost", tc.Get().Host)
+	at.Equal("foo", tc.Get().Name)
+	at.Zero(tc.Get().embeddedPort)
+}
+
+func TestNewStructSchema(t *testing.T) {
+	at := require.New(t)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"name": "foo", "prot": 80}`)))
+
+	_, err := nextcfg.New(typedCfg{}, withSource(src), nextcfg.WithStructSchema())
+	at.NotNil(err)
+	at.Contains(err.Error(), "schema validation failed")
+
+	tc, err := nextcfg.New(typedCfg{}, withSource(src))
+	at.Nil(err)
+	at.Equal("foo", tc.Get().Name)
+}
+
+type durationCfg struct {
+	Timeout time.Duration `json:"timeout"`
+}
+
+func TestNewStructSchemaDuration(t *testing.T) {
+	at := require.New(t)
+
+	// a document the schema accepts scans too
+	src := memory.NewSource(memory.WithJSON([]byte(`{"timeout": 5000000000}`)))
+	tc, err := nextcfg.New(durationCfg{}, withSource(src), nextcfg.WithStructSchema())
+	at.Nil(err)
+	at.Equal(5*time.Second, tc.Get().Timeout)
+
+	// and one Scan can't decode is rejected by the schema first
+	src = memory.NewSource(memory.WithJSON([]byte(`{"timeout": "5s"}`)))
+	_, err = nextcfg.New(durationCfg{}, withSource(src), nextcfg.WithStructSchema())
+	at.NotNil(err)
+	at.Contains(err.Error(), "schema validation failed")
+}
+
+func TestNewStrict(t *testing.T) {
+	at := require.New(t)
+
+	// every key is reported with the source it comes from, not the one loaded last
+	base := memory.NewSource(source.WithName("base"), memory.WithJSON([]byte(`{"name": 1, "port": 80}`)))
+	local := memory.NewSource(source.WithName("local"), memory.WithJSON([]byte(`{"prot": 8080, "extra": {"a": 1}}`)))
+	src := memory.NewSource(source.WithName("overlay"), memory.WithJSON([]byte(`{"extra": {"b": 2}}`)))
+
+	_, err := nextcfg.New(typedCfg{}, withSource(base), withSource(local), withSource(src), nextcfg.WithStrict())
+	at.NotNil(err)
+
+	var se reader.StrictErrors
+	at.True(errors.As(err, &se))
+	at.Len(se, 3)
+	at.Equal([]string{"extra"}, se[0].Path)
+	at.Equal("local,overlay", se[0].Source)
+	at.Equal([]string{"name"}, se[1].Path)
+	at.Equal("base", se[1].Source)
+	at.Equal([]string{"prot"}, se[2].Path)
+	at.Equal("unknown key", se[2].Message)
+	at.Equal("local", se[2].Source)
+
+	src = memory.NewSource(memory.WithJSON([]byte(`{"name": "foo", "port": 80}`)))
+
+	tc, err := nextcfg.New(typedCfg{}, withSource(src), nextcfg.WithStrict())
+	at.Nil(err)
+	at.Equal(80, tc.Get().Port)
+}